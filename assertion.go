@@ -33,19 +33,37 @@ func Assert(err error) (*Err, bool) {
 
 // AssertUnderlying is a convenience function that attempts to assert
 // an error to a *Err, and then attempts to assert its underlying
-// error to a *Err.
+// error to a *Err.  If the underlying error is not itself a *Err, but wraps
+// one (such as the result of fmt.Errorf with a %w verb), the nearest *Err
+// wrapped by it is returned instead.  If the nearest error that carries a
+// stack is not an *Err, but an error from github.com/pkg/errors or
+// github.com/bugsnag/bugsnag-go/errors, an *Err adopting its stack is
+// returned instead.
 func AssertUnderlying(err error) (*Err, error) {
 	e, ok := Assert(err)
 	if !ok {
 		return nil, newErrNotErr()
 	}
-	u, ok := Assert(e.Underlying)
+	u, ok := e.underlying()
 	if !ok {
 		return nil, newUnderlyingNotErr()
 	}
 	return u, nil
 }
 
+// underlying returns the *Err that AssertUnderlying returns for err, and
+// whether there is one, without making the error AssertUnderlying returns
+// where there is not, so that walking a chain of *Err captures no stacks.
+func (err *Err) underlying() (*Err, bool) {
+	if origin := err.adopted(); origin != nil {
+		return origin, true
+	}
+	return Assert(Find(err.Underlying, func(err error) bool {
+		_, ok := err.(*Err)
+		return ok
+	}))
+}
+
 // AssertNthUnderlying is a convenience function that attempts to assert
 // an error to a *Err, and then attempts to recursively assert its
 // underlying errors to a *Err, up to the nth (specified) underlying error.
@@ -55,8 +73,7 @@ func AssertNthUnderlying(err error, nth int) (u *Err, ierr error) {
 		return nil, newErrNotErr()
 	}
 	for i := 0; i < nth; i++ {
-		u, ierr = AssertUnderlying(u)
-		if ierr != nil {
+		if u, ok = u.underlying(); !ok {
 			return nil, newUnderlyingNotErr()
 		}
 	}
@@ -64,18 +81,16 @@ func AssertNthUnderlying(err error, nth int) (u *Err, ierr error) {
 }
 
 // AssertDeepestUnderlying is a convenience function that attempts to return
-// the deepest underlying *Err in a stack of errors.
+// the deepest underlying *Err in a stack of errors.  Where an error wraps
+// several errors, the first *Err found is followed.  Errors from
+// github.com/pkg/errors and github.com/bugsnag/bugsnag-go/errors that carry
+// a stack are recognised as described by AssertUnderlying, so that the stack
+// returned by the deepest *Err may be the origin stack of such an error.
 func AssertDeepestUnderlying(err error) (u *Err, ierr error) {
 	u, ok := Assert(err)
 	if !ok {
 		return nil, newErrNotErr()
 	}
-	var u2 *Err
-	for ierr == nil {
-		u2, ierr = AssertUnderlying(u)
-		if u2 != nil {
-			u = u2
-		}
-	}
-	return u, nil
+	layers := u.layers()
+	return layers[len(layers)-1], nil
 }
@@ -103,6 +103,16 @@ func TestAssertUnderlying(t *testing.T) {
 		t.Errorf(assertUnderlyingFailed, errWrongUnderlyingError)
 	}
 
+	// test case: *Err with plain underlying wrapping an *Err
+	parentError = func() error { return New(fmt.Errorf("%v: %w", testMsgFoo, underlying)) }()
+	u, err = AssertUnderlying(parentError)
+	if err != nil {
+		t.Errorf(assertUnderlyingFailed, errUnderlyingErrorNotAsserted)
+	}
+	if u != underlying {
+		t.Errorf(assertUnderlyingFailed, errWrongUnderlyingError)
+	}
+
 }
 
 func TestAssertNthUnderlying(t *testing.T) {
@@ -161,4 +171,29 @@ func TestAssertDeepestUnderlying(t *testing.T) {
 	if u != wrap3 {
 		t.Errorf(assertDeepestUnderlyingFailed, errWrongUnderlyingError)
 	}
+
+	// test case: *Err with underlying plain error wrapping an *Err with
+	// underlying plain error
+	// seeking deepest underlying error
+	wrap1 = Wrapf(fmt.Errorf("%v: %w", testMsgFoo, wrap3), testPrefixFoobar, 1)
+	u, err = AssertDeepestUnderlying(wrap1)
+	if err != nil {
+		t.Errorf(assertDeepestUnderlyingFailed, err)
+	}
+	if u != wrap3 {
+		t.Errorf(assertDeepestUnderlyingFailed, errWrongUnderlyingError)
+	}
+
+	// test case: *Err whose chain loops back upon itself
+	// seeking deepest underlying error
+	loop := &loopErr{}
+	wrap1 = New(loop)
+	loop.next = wrap1
+	u, err = AssertDeepestUnderlying(wrap1)
+	if err != nil {
+		t.Errorf(assertDeepestUnderlyingFailed, err)
+	}
+	if u != wrap1 {
+		t.Errorf(assertDeepestUnderlyingFailed, errWrongUnderlyingError)
+	}
 }
@@ -0,0 +1,201 @@
+package errors
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// internStacks is set to 1 while stacks are interned, as set by
+// SetInternStacks.
+var internStacks int32
+
+// SetInternStacks determines whether identical stacks captured by New, Wrap,
+// Wrapf and Errorf share a single slice of program counters, so that the
+// many errors made at the same site in a busy program share one stack.  The
+// slices returned by Callers and ParentCallers must not be modified while
+// stacks are interned.  It may be called while errors are being made on
+// other goroutines, and applies to the stacks captured after it returns.
+func SetInternStacks(intern bool) {
+	var v int32
+	if intern {
+		v = 1
+	}
+	atomic.StoreInt32(&internStacks, v)
+}
+
+// maxInternedStacks bounds the number of distinct stacks interned, so that a
+// program making errors at ever more distinct sites does not grow without
+// limit.  Stacks captured once the bound is reached are not interned.
+const maxInternedStacks = 4096
+
+// scratchPool holds buffers that stacks are captured into, before being
+// copied into slices of the right size.
+var scratchPool = sync.Pool{
+	New: func() interface{} { return new([]uintptr) },
+}
+
+// interned holds the interned stacks, by their hash.
+var interned = struct {
+	sync.RWMutex
+	stacks map[uint64][]uintptr
+}{stacks: make(map[uint64][]uintptr)}
+
+// maxWalkedStackDepth bounds the depth searched for the bottom of a stack too
+// deep to be captured whole, so that an error made deep within runaway
+// recursion is not costly to make.  Frames beyond it are neither reported nor
+// counted as elided.
+const maxWalkedStackDepth = 1 << 16
+
+// captureStack returns the program counters of the calling goroutine's
+// stack, up to depth of them.  The skip parameter indicates how far up the
+// stack to start; 0 is from the caller of captureStack, 1 from its caller,
+// etc.  Helpers at the top of the stack, as marked by Helper, are skipped in
+// addition.  If the stack is deeper than depth, up to tail of the program
+// counters returned, and at most half of them, are those at its bottom, and
+// the number of program counters elided, and the index at which they were
+// elided, are also returned.  No more than depth+1 program counters are held
+// at once, however deep the stack.
+func captureStack(skip, depth, tail int) (stack []uintptr, elided int, elidedAt int) {
+	if depth <= 0 {
+		return nil, 0, 0
+	}
+
+	// one more than depth is captured, to tell whether the stack is deeper
+	scratch := scratchPool.Get().(*[]uintptr)
+	if cap(*scratch) <= depth {
+		*scratch = make([]uintptr, depth+1)
+	}
+	buf := (*scratch)[:depth+1]
+	pcs := buf[:runtime.Callers(2+skip, buf)]
+	if atomic.LoadInt32(&helpersRegistered) != 0 {
+		for helpers := skipHelperCallers(pcs); helpers > 0; helpers = skipHelperCallers(pcs) {
+			if len(pcs) < len(buf) {
+				pcs = pcs[helpers:]
+				break
+			}
+			// the helpers are skipped by runtime.Callers, so that the frames
+			// below them fill the buffer
+			skip += helpers
+			pcs = buf[:runtime.Callers(2+skip, buf)]
+		}
+	}
+
+	if len(pcs) > depth {
+		if tail > depth/2 {
+			tail = depth / 2
+		}
+		elidedAt = depth - tail
+		// the frame of captureStackBottom is skipped as well
+		bottom := captureStackBottom(3+skip, len(pcs), buf[elidedAt:depth])
+		elided = bottom - depth
+		pcs = pcs[:depth]
+	}
+
+	if atomic.LoadInt32(&internStacks) != 0 {
+		stack = internStack(pcs)
+	} else {
+		stack = make([]uintptr, len(pcs))
+		copy(stack, pcs)
+	}
+	scratchPool.Put(scratch)
+	return stack, elided, elidedAt
+}
+
+// captureStackBottom fills buf with the program counters at the bottom of the
+// calling goroutine's stack, and returns the depth of the stack counted from
+// skip, as by runtime.Callers, which is known to be at least known.  The bottom
+// is searched for with calls to runtime.Callers that capture no more than
+// len(buf) program counters, rather than by capturing the whole stack.
+func captureStackBottom(skip, known int, buf []uintptr) int {
+	n := len(buf)
+	if n == 0 {
+		// the depth is still searched for, to count the frames elided
+		var probe [1]uintptr
+		buf, n = probe[:], 1
+	}
+
+	// the stack is at least low deep and at most high deep, and is searched
+	// for further away each time until it is passed, and then between the two
+	low, high := known, maxWalkedStackDepth
+	passed := false
+	for low < high {
+		at := 2 * low
+		if passed || at > high {
+			at = low + (high-low+1)/2
+		}
+		// a probe at at-n captures n program counters if the stack is at
+		// least at deep, and none if it is no deeper than at-n
+		at -= n
+		if at < 0 {
+			at = 0
+		}
+		got := runtime.Callers(skip+at, buf[:n])
+		switch {
+		case got == n:
+			low = at + n
+		case got == 0:
+			high, passed = at, true
+		default:
+			low, high = at+got, at+got
+		}
+	}
+
+	if low >= n {
+		runtime.Callers(skip+low-n, buf[:n])
+	}
+	return low
+}
+
+// internStack returns the interned stack identical to pcs, interning a copy
+// of pcs if there is none.
+func internStack(pcs []uintptr) []uintptr {
+	hash := hashStack(pcs)
+
+	interned.RLock()
+	stack, ok := interned.stacks[hash]
+	interned.RUnlock()
+	if ok && equalStacks(stack, pcs) {
+		return stack
+	}
+
+	stack = make([]uintptr, len(pcs))
+	copy(stack, pcs)
+	// on a hash collision, the stack already interned is kept
+	if !ok {
+		interned.Lock()
+		if len(interned.stacks) < maxInternedStacks {
+			if _, ok := interned.stacks[hash]; !ok {
+				interned.stacks[hash] = stack
+			}
+		}
+		interned.Unlock()
+	}
+	return stack
+}
+
+// hashStack returns the 64 bit FNV-1a hash of pcs.
+func hashStack(pcs []uintptr) uint64 {
+	h := fnv.New64a()
+	var scratch [8]byte
+	for _, pc := range pcs {
+		binary.LittleEndian.PutUint64(scratch[:], uint64(pc))
+		h.Write(scratch[:])
+	}
+	return h.Sum64()
+}
+
+// equalStacks reports whether a and b hold the same program counters.
+func equalStacks(a, b []uintptr) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,194 @@
+package errors
+
+import (
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// error strings used in tests
+// declared globally to allow reuse for consistency
+const (
+	errStacksNotShared = "identical stacks were not interned into a single slice"
+	errStacksShared    = "distinct stacks were interned into a single slice"
+	errNotElided       = "the frames elided from a deep stack were not marked"
+)
+
+// recurse makes an error at the bottom of n nested calls.
+//
+//go:noinline // each call must have a frame of its own
+func recurse(n int) *Err {
+	if n == 0 {
+		return New(testMsgFoo)
+	}
+	return recurse(n - 1)
+}
+
+func TestInternStacks(t *testing.T) {
+	SetInternStacks(true)
+	defer SetInternStacks(false)
+
+	// errors made by the same call, with identical stacks
+	var errs [2]*Err
+	for i := range errs {
+		errs[i] = New("interned")
+	}
+	a, b := errs[0], errs[1]
+	if &a.Callers()[0] != &b.Callers()[0] {
+		t.Error(errStacksNotShared)
+	}
+	if !equalStacks(a.Callers(), b.Callers()) {
+		t.Error(errStacksNotMatch)
+	}
+
+	c := New("elsewhere")
+	if &a.Callers()[0] == &c.Callers()[0] {
+		t.Error(errStacksShared)
+	}
+}
+
+func TestCaptureStackDepth(t *testing.T) {
+	if stack, _, _ := captureStack(0, 0, 0); stack != nil {
+		t.Errorf("captureStack with depth 0 returned %d frames", len(stack))
+	}
+	if stack, _, _ := captureStack(0, 1, defaultTailDepth); len(stack) != 1 {
+		t.Errorf("captureStack with depth 1 returned %d frames", len(stack))
+	}
+}
+
+func TestEqualStacks(t *testing.T) {
+	cases := []struct {
+		a, b  []uintptr
+		equal bool
+	}{
+		{nil, nil, true},
+		{[]uintptr{1, 2}, []uintptr{1, 2}, true},
+		{[]uintptr{1, 2}, []uintptr{1}, false},
+		{[]uintptr{1, 2}, []uintptr{2, 1}, false},
+	}
+	for _, c := range cases {
+		if equal := equalStacks(c.a, c.b); equal != c.equal {
+			t.Errorf("equalStacks(%v, %v) = %t", c.a, c.b, equal)
+		}
+		if c.equal && hashStack(c.a) != hashStack(c.b) {
+			t.Errorf("hashStack differs for %v and %v", c.a, c.b)
+		}
+	}
+}
+
+func TestCaptureStackElided(t *testing.T) {
+	err := recurse(2 * MaxStackDepth)
+	frames := err.StackFrames()
+	if len(err.Callers()) != MaxStackDepth || len(frames) != MaxStackDepth+1 {
+		t.Fatalf("%d program counters and %d frames were captured", len(err.Callers()), len(frames))
+	}
+
+	// the top of the stack, a marker, then the bottom of the stack
+	elided, at := err.Elided()
+	if at != MaxStackDepth-defaultTailDepth {
+		t.Fatalf("frames were elided at %d", at)
+	}
+	marker := frames[at]
+	if marker.Elided != elided || elided <= MaxStackDepth || frames[at-1].Name != "recurse" {
+		t.Fatal(errNotElided)
+	}
+	if !frames[at+1:].Contains("TestCaptureStackElided") {
+		t.Errorf("the bottom of a deep stack was not captured")
+	}
+	if frames[0].Name != "recurse" || frames[len(frames)-1].Name != "goexit" {
+		t.Errorf("the stack runs from %s to %s", frames[0].Name, frames[len(frames)-1].Name)
+	}
+
+	marked := "\n... " + strconv.Itoa(marker.Elided) + " frames elided ...\n"
+	if !strings.Contains(err.ErrorStack(), marked) {
+		t.Error(errNotElided)
+	}
+}
+
+// captureAtDepth captures the stack at the bottom of n nested calls with
+// captureStack, and whole with runtime.Callers.
+//
+//go:noinline // each call must have a frame of its own
+func captureAtDepth(n, depth, tail int) (stack []uintptr, elided, elidedAt int, whole []uintptr) {
+	if n == 0 {
+		stack, elided, elidedAt = captureStack(0, depth, tail)
+		whole = make([]uintptr, maxWalkedStackDepth)
+		return stack, elided, elidedAt, whole[:runtime.Callers(1, whole)]
+	}
+	return captureAtDepth(n-1, depth, tail)
+}
+
+func TestCaptureStackBottom(t *testing.T) {
+	for _, n := range []int{0, 40, 45, 46, 47, 100, 1000, 5000} {
+		for _, tail := range []int{0, 1, 10, 25, 40} {
+			stack, elided, at, whole := captureAtDepth(n, MaxStackDepth, tail)
+			if len(whole) <= MaxStackDepth {
+				if elided != 0 || len(stack) != len(whole) {
+					t.Errorf("%d of a stack %d deep were elided", elided, len(whole))
+				}
+				continue
+			}
+
+			if tail > MaxStackDepth/2 {
+				tail = MaxStackDepth / 2
+			}
+			if len(stack) != MaxStackDepth || at != MaxStackDepth-tail || elided != len(whole)-MaxStackDepth {
+				t.Errorf("%d of a stack %d deep were elided at %d, with a tail of %d", elided, len(whole), at, tail)
+				continue
+			}
+			// the top frame is of a different call to each
+			if !equalStacks(stack[1:at], whole[1:at]) || !equalStacks(stack[at:], whole[len(whole)-tail:]) {
+				t.Errorf("the stack %d deep, with a tail of %d, was not captured", len(whole), tail)
+			}
+		}
+	}
+}
+
+func TestCaptureStackNotElided(t *testing.T) {
+	// a stack of exactly the depth captured is captured whole
+	for _, frame := range New(testMsgFoo, Depth(len(New(testMsgFoo).Callers()))).StackFrames() {
+		if frame.Elided != 0 {
+			t.Errorf("frames were marked as elided from a stack captured whole")
+		}
+	}
+}
+
+// captureStackUnpooled captures a stack in the way New did before stacks
+// were captured into pooled buffers, for comparison by the benchmarks below.
+//
+//go:noinline // must have a frame of its own, as captureStack does
+func captureStackUnpooled(skip, depth int) []uintptr {
+	stack := make([]uintptr, depth)
+	length := runtime.Callers(2+skip, stack[:])
+	return stack[:length]
+}
+
+func BenchmarkCaptureStack(b *testing.B) {
+	atDepth(benchmarkDepth, func() {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_, _, _ = captureStack(0, MaxStackDepth, defaultTailDepth)
+		}
+	})
+}
+
+func BenchmarkCaptureStackUnpooled(b *testing.B) {
+	atDepth(benchmarkDepth, func() {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_ = captureStackUnpooled(0, MaxStackDepth)
+		}
+	})
+}
+
+func BenchmarkCaptureStackInterned(b *testing.B) {
+	SetInternStacks(true)
+	defer SetInternStacks(false)
+	atDepth(benchmarkDepth, func() {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_, _, _ = captureStack(0, MaxStackDepth, defaultTailDepth)
+		}
+	})
+}
@@ -0,0 +1,59 @@
+package errors
+
+import (
+	"bytes"
+	"strconv"
+)
+
+// ChainStack returns a string describing every *Err in the chain of nested
+// *Err, starting with the *Err this is called on, in the manner of a Java
+// stack trace.  Each layer's type name and message, including its prefix,
+// is followed by its fields and its own stack, and each nested layer is
+// introduced by "Caused by: ".  The frames at the bottom of a nested layer's
+// stack that it shares with the layer wrapping it are not repeated, but are
+// counted by a "... N more" line, so that the chain shows where each layer
+// was made without repeating the calls that led there.  Nested *Err are not
+// described beyond a layer that has ignoreNestedStack set, as with the %+v
+// verb.
+func (err *Err) ChainStack() string {
+	buf := bytes.Buffer{}
+	var outer Stack
+	for i, e := range err.layers() {
+		if i > 0 {
+			buf.WriteString("Caused by: ")
+		}
+		buf.WriteString(e.TypeName())
+		buf.WriteByte(' ')
+		buf.WriteString(e.Error())
+		buf.WriteByte('\n')
+		buf.WriteString(formatFields(e.fields))
+
+		frames := e.ParentStackFrames()
+		shared := frames.shared(outer)
+		frames[:len(frames)-shared].Collapsed().writeTo(&buf)
+		if shared > 0 {
+			buf.WriteString("... ")
+			buf.WriteString(strconv.Itoa(shared))
+			buf.WriteString(" more\n")
+		}
+
+		if e.ignoreNestedStack {
+			break
+		}
+		// a layer without a stack shares no frames with those it wraps
+		if len(frames) > 0 {
+			outer = frames
+		}
+	}
+	return buf.String()
+}
+
+// shared returns the number of frames at the bottom of the stack that are
+// the same as those at the bottom of outer.
+func (s Stack) shared(outer Stack) int {
+	n := 0
+	for n < len(s) && n < len(outer) && s[len(s)-1-n] == outer[len(outer)-1-n] {
+		n++
+	}
+	return n
+}
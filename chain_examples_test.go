@@ -0,0 +1,18 @@
+package errors
+
+import (
+	"fmt"
+	"io"
+)
+
+func ExampleErr_ChainStack() {
+	err := Wrapf(New(io.EOF), "loading %s", 0, "config.yaml")
+
+	// prints:
+	//   *errors.Err loading config.yaml: EOF
+	//   <the stack of the outer layer>
+	//   Caused by: *errors.errorString EOF
+	//   <the frames of the inner layer not shared with the outer layer>
+	//   ... N more
+	fmt.Print(err.ChainStack())
+}
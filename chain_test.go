@@ -0,0 +1,67 @@
+package errors
+
+import (
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// error strings used in tests
+// declared globally to allow reuse for consistency
+const (
+	errChainWrongFormat = ".ChainStack() is in the wrong format"
+)
+
+// the following are not inlined, so that each layer of the chain is made in
+// a function of its own
+
+//go:noinline
+func chainInner() *Err {
+	return New(io.EOF)
+}
+
+//go:noinline
+func chainOuter() *Err {
+	return Wrapf(chainInner(), "loading %s", 0, "config")
+}
+
+func TestChainStack(t *testing.T) {
+	err := chainOuter()
+	inner, _ := AssertUnderlying(err)
+	chain := err.ChainStack()
+
+	parts := strings.Split(chain, "Caused by: ")
+	if len(parts) != 2 {
+		t.Fatalf("%s:\n%s", errChainWrongFormat, chain)
+	}
+	if parts[0] != err.ParentErrorStack() {
+		t.Errorf("%s; the outer layer was described as:\n%s", errChainWrongFormat, parts[0])
+	}
+
+	// only chainInner and chainOuter are not shared with the outer layer
+	outerFrames := err.ParentStackFrames()
+	want := inner.TypeName() + " " + inner.Error() + "\n" +
+		inner.ParentStackFrames()[:2].String() +
+		"... " + strconv.Itoa(len(outerFrames)-1) + " more\n"
+	if parts[1] != want {
+		t.Errorf("%s; the inner layer was described as:\n%s\nrather than:\n%s", errChainWrongFormat, parts[1], want)
+	}
+}
+
+func TestChainStackIgnoreNestedStack(t *testing.T) {
+	err := chainOuter().SetIgnoreNestedStack(true)
+	if chain := err.ChainStack(); chain != err.ParentErrorStack() {
+		t.Errorf("%s; nested layers were described beyond one ignoring them:\n%s", errChainWrongFormat, chain)
+	}
+}
+
+func TestChainStackNoStack(t *testing.T) {
+	// the layer without a stack shares no frames, and does not hide the
+	// frames the outer layer shares with the inner one
+	err := Wrap(New(New(io.EOF), NoStack()), 0)
+	chain := err.ChainStack()
+	if strings.Count(chain, "Caused by: ") != 2 || !strings.Contains(chain, " more\n") {
+		t.Errorf("%s:\n%s", errChainWrongFormat, chain)
+	}
+}
@@ -1,21 +1,179 @@
 package errors
 
+import "reflect"
+
+// causer is implemented by errors that follow the github.com/pkg/errors
+// convention of exposing their cause.
+type causer interface {
+	Cause() error
+}
+
+// wrapper is implemented by errors that wrap a single error, as produced by
+// fmt.Errorf with a %w verb.
+type wrapper interface {
+	Unwrap() error
+}
+
+// multiWrapper is implemented by errors that wrap several errors, as
+// produced by errors.Join or fmt.Errorf with several %w verbs.
+type multiWrapper interface {
+	Unwrap() []error
+}
+
 // Is detects whether the error is equal to a given error. Errors
 // are considered equal by this function if they are the same object,
-// or if they both contain the same error inside an errors.Error.
+// or if they both contain the same error inside an *Err.  Every layer of e
+// is considered, whether it is wrapped by an *Err, by a github.com/pkg/errors
+// style Cause() method, by fmt.Errorf's %w, or by a multi-error implementing
+// Unwrap() []error.  Layers that implement an Is(error) bool method are
+// consulted in the same way as the standard library's errors.Is does.  Layers
+// are compared using Equal, so Is never panics on errors whose dynamic types
+// cannot be compared with ==.
 func Is(e error, original error) bool {
+	if e == nil || original == nil {
+		return e == original
+	}
 
-	if e == original {
-		return true
+	// original matches both itself and anything nested within it by *Err
+	targets := []error{original}
+	for o, ok := Assert(original); ok && o.Underlying != nil; o, ok = Assert(o.Underlying) {
+		targets = append(targets, o.Underlying)
 	}
 
-	if e, ok := e.(*Err); ok {
-		return Is(e.Underlying, original)
+	return Find(e, func(layer error) bool {
+		for _, target := range targets {
+			if Equal(layer, target) {
+				return true
+			}
+			if i, ok := layer.(interface{ Is(error) bool }); ok && i.Is(target) {
+				return true
+			}
+		}
+		return false
+	}) != nil
+}
+
+// As finds the first layer of err that is of type T, and returns it.  Layers
+// are walked in the same way as by Is, and layers that implement an
+// As(interface{}) bool method are consulted in the same way as the standard
+// library's errors.As does.  The zero value of T, and false, are returned if
+// no layer matches.
+func As[T any](err error) (T, bool) {
+	var target T
+	found := Find(err, func(layer error) bool {
+		if t, ok := layer.(T); ok {
+			target = t
+			return true
+		}
+		if a, ok := layer.(interface{ As(interface{}) bool }); ok && a.As(&target) {
+			return true
+		}
+		return false
+	})
+	return target, found != nil
+}
+
+// Find walks every layer of err, starting with err itself, and returns the
+// first layer for which pred returns true, or nil if there is none.  Layers
+// are walked depth first, following *Err, Cause() error, Unwrap() error and
+// Unwrap() []error, with each layer being visited at most once so that
+// chains containing loops terminate.
+func Find(err error, pred func(error) bool) error {
+	// most chains are short, so are walked without allocating where possible
+	var buf [4]error
+	pending := append(buf[:0], err)
+	// only allocated once a layer wrapping others is found, as only those
+	// can form loops
+	var seen map[error]struct{}
+	for len(pending) > 0 {
+		layer := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		if layer == nil {
+			continue
+		}
+		// uncomparable values cannot be used as map keys, but they also cannot
+		// form loops by themselves
+		comparable := reflect.ValueOf(layer).Comparable()
+		if seen != nil && comparable {
+			if _, ok := seen[layer]; ok {
+				continue
+			}
+			seen[layer] = struct{}{}
+		}
+
+		if pred(layer) {
+			return layer
+		}
+
+		n := len(pending)
+		pending = appendUnwrapped(pending, layer)
+		if len(pending) == n {
+			continue
+		}
+		if seen == nil {
+			seen = make(map[error]struct{})
+			if comparable {
+				seen[layer] = struct{}{}
+			}
+		}
+		// reverse the children, so that the first wrapped error is visited first
+		for i, j := n, len(pending)-1; i < j; i, j = i+1, j-1 {
+			pending[i], pending[j] = pending[j], pending[i]
+		}
+	}
+	return nil
+}
+
+// Equal reports whether a and b are the same error.  Errors are compared with
+// == when that is safe to do so.  When a and b share a dynamic type that
+// cannot be compared with ==, such as a struct holding a slice or a map, a
+// is compared with an Equal(error) bool method if it has one, and
+// structurally with reflect.DeepEqual otherwise, rather than panicking.
+func Equal(a, b error) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	// interface values with different dynamic types are never equal, and
+	// never panic when compared
+	if reflect.TypeOf(a) != reflect.TypeOf(b) {
+		return false
+	}
+	if reflect.ValueOf(a).Comparable() {
+		return a == b
 	}
+	if e, ok := a.(interface{ Equal(error) bool }); ok {
+		return e.Equal(b)
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// unwrapAll returns the errors directly wrapped by err, following every
+// wrapping protocol that err implements.
+func unwrapAll(err error) []error {
+	return appendUnwrapped(nil, err)
+}
 
-	if original, ok := original.(*Err); ok {
-		return Is(e, original.Underlying)
+// appendUnwrapped appends the errors directly wrapped by err to errs, as
+// described by unwrapAll, and returns the extended slice.
+func appendUnwrapped(errs []error, err error) []error {
+	switch e := err.(type) {
+	case *Err:
+		return append(errs, e.Underlying)
+	case multiWrapper:
+		return append(errs, e.Unwrap()...)
 	}
 
-	return false
+	var unwrapped error
+	w, isWrapper := err.(wrapper)
+	if isWrapper {
+		unwrapped = w.Unwrap()
+		errs = append(errs, unwrapped)
+	}
+	// errors from github.com/pkg/errors implement both, returning the same
+	if c, ok := err.(causer); ok {
+		if cause := c.Cause(); !isWrapper || !Equal(cause, unwrapped) {
+			errs = append(errs, cause)
+		}
+	}
+	return errs
 }
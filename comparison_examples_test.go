@@ -2,7 +2,10 @@ package errors
 
 import (
 	"bytes"
+	"fmt"
 	"io"
+	"io/fs"
+	"os"
 )
 
 func ExampleIs() {
@@ -18,3 +21,27 @@ func ExampleIs() {
 		return
 	}
 }
+
+func ExampleAs() {
+	// open a file that does not exist
+	_, err := os.Open("/nonexistent")
+	// wrap the error, adding a stacktrace
+	err = Wrapf(err, "loading config", 0)
+	// find the *fs.PathError within the error's chain
+	if pathErr, ok := As[*fs.PathError](err); ok {
+		fmt.Println(pathErr.Path)
+	}
+	// Output: /nonexistent
+}
+
+func ExampleFind() {
+	// an error with an *Err hidden behind a standard library wrapper
+	err := fmt.Errorf("reading header: %w", New(io.ErrUnexpectedEOF))
+	// find the layer that has a stacktrace attached
+	layer := Find(err, func(err error) bool {
+		_, ok := err.(*Err)
+		return ok
+	})
+	fmt.Println(layer)
+	// Output: unexpected EOF
+}
@@ -1,17 +1,46 @@
 package errors
 
 import (
+	stderrors "errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"testing"
+
+	pkgerrors "github.com/pkg/errors"
 )
 
 // error format strings used in this file for consistency
 const (
 	evaluatedIncorrectlySame      = "evaluated %v as the same as %v when it is not"
 	evaluatedIncorrectlyDifferent = "evaluated %v as different to %v when it is not"
+	asFailed                      = "As() failed for %v; %v"
+	findFailed                    = "Find() failed for %v; %v"
+	equalFailed                   = "Equal() failed for %v; %v"
 )
 
+// error strings used in this file
+const (
+	errNotFound      = "a matching layer was not found"
+	errWrongLayer    = "the wrong layer was returned"
+	errFoundSpurious = "a layer was found when none should have been"
+)
+
+// loopErr is an error whose chain loops back upon itself
+type loopErr struct {
+	next error
+}
+
+func (e *loopErr) Error() string { return "loop" }
+func (e *loopErr) Unwrap() error { return e.next }
+
+// isAllErr claims to be equal to every error
+type isAllErr struct{}
+
+func (isAllErr) Error() string       { return "is all" }
+func (isAllErr) Is(error) bool       { return true }
+func (isAllErr) As(interface{}) bool { return false }
+
 func TestIs(t *testing.T) {
 
 	if Is(nil, io.EOF) {
@@ -42,4 +71,186 @@ func TestIs(t *testing.T) {
 		t.Errorf(evaluatedIncorrectlyDifferent, "New(New(io.EOF))", "WrapPrefix(WrapPrefix(io.EOF, testMsgFoo, 1), testPrefixFoobar, 1)")
 	}
 
+	if !Is(pkgerrors.Wrap(New(io.EOF), testMsgFoo), io.EOF) {
+		t.Errorf(evaluatedIncorrectlyDifferent, "pkgerrors.Wrap(New(io.EOF))", "io.EOF")
+	}
+
+	if !Is(New(fmt.Errorf("%w", New(io.EOF))), New(io.EOF)) {
+		t.Errorf(evaluatedIncorrectlyDifferent, "New(fmt.Errorf(\"%w\", New(io.EOF)))", "New(io.EOF)")
+	}
+
+	if !Is(New(stderrors.Join(io.ErrUnexpectedEOF, New(io.EOF))), io.EOF) {
+		t.Errorf(evaluatedIncorrectlyDifferent, "New(stderrors.Join(io.ErrUnexpectedEOF, New(io.EOF)))", "io.EOF")
+	}
+
+	if Is(New(stderrors.Join(io.ErrUnexpectedEOF, New(io.EOF))), fs.ErrNotExist) {
+		t.Errorf(evaluatedIncorrectlySame, "New(stderrors.Join(io.ErrUnexpectedEOF, New(io.EOF)))", "fs.ErrNotExist")
+	}
+
+	if !Is(New(isAllErr{}), io.EOF) {
+		t.Errorf(evaluatedIncorrectlyDifferent, "New(isAllErr{})", "io.EOF")
+	}
+
+	loop := &loopErr{}
+	loop.next = New(loop)
+	if Is(loop, io.EOF) {
+		t.Errorf(evaluatedIncorrectlySame, "loop", "io.EOF")
+	}
+}
+
+// validationErr cannot be compared with ==, and has no Equal method
+type validationErr struct {
+	fields []string
+}
+
+func (e validationErr) Error() string { return fmt.Sprint("invalid fields: ", e.fields) }
+
+// equalerErr cannot be compared with ==, but has an Equal method that only
+// considers its code
+type equalerErr struct {
+	code    int
+	details map[string]string
+}
+
+func (e equalerErr) Error() string { return fmt.Sprint("code ", e.code) }
+func (e equalerErr) Equal(other error) bool {
+	o, ok := other.(equalerErr)
+	return ok && o.code == e.code
+}
+
+// holderErr is of a comparable type, but may hold an uncomparable value
+type holderErr struct {
+	held error
+}
+
+func (e holderErr) Error() string { return "holding " + e.held.Error() }
+
+func TestEqual(t *testing.T) {
+	cases := []struct {
+		name  string
+		a, b  error
+		equal bool
+	}{
+		{"nil and nil", nil, nil, true},
+		{"nil and io.EOF", nil, io.EOF, false},
+		{"io.EOF and io.EOF", io.EOF, io.EOF, true},
+		{"io.EOF and io.ErrUnexpectedEOF", io.EOF, io.ErrUnexpectedEOF, false},
+		{"different types", validationErr{[]string{"a"}}, io.EOF, false},
+		{"identical uncomparable", validationErr{[]string{"a"}}, validationErr{[]string{"a"}}, true},
+		{"different uncomparable", validationErr{[]string{"a"}}, validationErr{[]string{"b"}}, false},
+		{"Equal method equal", equalerErr{1, map[string]string{"a": "b"}}, equalerErr{1, nil}, true},
+		{"Equal method different", equalerErr{1, nil}, equalerErr{2, nil}, false},
+		{"comparable holding uncomparable", holderErr{validationErr{[]string{"a"}}}, holderErr{validationErr{[]string{"a"}}}, true},
+		{"comparable holding different uncomparable", holderErr{validationErr{[]string{"a"}}}, holderErr{validationErr{[]string{"b"}}}, false},
+		{"comparable holding comparable", holderErr{io.EOF}, holderErr{io.EOF}, true},
+		{"comparable holding mixed", holderErr{io.EOF}, holderErr{validationErr{[]string{"a"}}}, false},
+	}
+
+	for _, c := range cases {
+		if Equal(c.a, c.b) != c.equal {
+			t.Errorf(equalFailed, c.name, errWrongLayer)
+		}
+	}
+}
+
+func TestIsUncomparable(t *testing.T) {
+	invalid := validationErr{[]string{"name", "email"}}
+
+	if !Is(New(invalid), validationErr{[]string{"name", "email"}}) {
+		t.Errorf(evaluatedIncorrectlyDifferent, "New(invalid)", "invalid")
+	}
+
+	if Is(New(invalid), validationErr{[]string{"name"}}) {
+		t.Errorf(evaluatedIncorrectlySame, "New(invalid)", "validationErr{[]string{\"name\"}}")
+	}
+
+	if !Is(New(New(invalid)), New(invalid)) {
+		t.Errorf(evaluatedIncorrectlyDifferent, "New(New(invalid))", "New(invalid)")
+	}
+
+	if !stderrors.Is(New(New(invalid)), New(invalid)) {
+		t.Errorf(evaluatedIncorrectlyDifferent, "New(New(invalid))", "New(invalid)")
+	}
+
+	if !Is(New(equalerErr{1, nil}), equalerErr{1, map[string]string{"a": "b"}}) {
+		t.Errorf(evaluatedIncorrectlyDifferent, "New(equalerErr{1, nil})", "equalerErr{1, ...}")
+	}
+
+	if Is(New(holderErr{invalid}), holderErr{validationErr{}}) {
+		t.Errorf(evaluatedIncorrectlySame, "New(holderErr{invalid})", "holderErr{validationErr{}}")
+	}
+
+	if !Is(stderrors.Join(holderErr{invalid}, invalid), invalid) {
+		t.Errorf(evaluatedIncorrectlyDifferent, "stderrors.Join(holderErr{invalid}, invalid)", "invalid")
+	}
+}
+
+func TestAs(t *testing.T) {
+	// test case: *Err beneath a pkg/errors wrapper
+	inner := New(io.EOF)
+	e, ok := As[*Err](pkgerrors.WithMessage(inner, testMsgFoo))
+	if !ok || e != inner {
+		t.Errorf(asFailed, "*Err", errWrongLayer)
+	}
+
+	// test case: *PathError beneath a multi-error beneath an *Err
+	pathErr := &fs.PathError{Op: "open", Path: "/nonexistent", Err: fs.ErrNotExist}
+	p, ok := As[*fs.PathError](New(stderrors.Join(io.EOF, fmt.Errorf("%w", pathErr))))
+	if !ok || p != pathErr {
+		t.Errorf(asFailed, "*fs.PathError", errWrongLayer)
+	}
+
+	// test case: interface target
+	c, ok := As[causer](New(pkgerrors.WithMessage(io.EOF, testMsgFoo)))
+	if !ok {
+		t.Errorf(asFailed, "causer", errNotFound)
+	} else if _, isErr := c.(*Err); !isErr {
+		t.Errorf(asFailed, "causer", errWrongLayer)
+	}
+
+	// test case: *Err embedded in ErrNotErr, found through its As hook
+	_, notErr := AssertUnderlying(io.EOF)
+	e, ok = As[*Err](fmt.Errorf("%w", notErr))
+	if !ok || e != notErr.(*ErrNotErr).Err {
+		t.Errorf(asFailed, "*Err", errWrongLayer)
+	}
+
+	// test case: no matching layer
+	if p, ok := As[*fs.PathError](New(io.EOF)); ok || p != nil {
+		t.Errorf(asFailed, "*fs.PathError", errFoundSpurious)
+	}
+}
+
+func TestFind(t *testing.T) {
+	// test case: the matching layer, rather than the top layer, is returned
+	middle := pkgerrors.WithMessage(New(io.EOF), testMsgFoo)
+	top := New(fmt.Errorf("%w", middle))
+	found := Find(top, func(err error) bool {
+		_, ok := err.(causer)
+		_, isErr := err.(*Err)
+		return ok && !isErr
+	})
+	if found != middle {
+		t.Errorf(findFailed, "pkgerrors.WithMessage", errWrongLayer)
+	}
+
+	// test case: every branch of a multi-error is visited, in order
+	var visited []error
+	Find(stderrors.Join(io.EOF, New(io.ErrUnexpectedEOF)), func(err error) bool {
+		visited = append(visited, err)
+		return false
+	})
+	if len(visited) != 4 || visited[1] != io.EOF || visited[3] != io.ErrUnexpectedEOF {
+		t.Errorf(findFailed, "stderrors.Join", errWrongLayer)
+	}
+
+	// test case: loops terminate, and nil matches nothing
+	loop := &loopErr{}
+	loop.next = New(loop)
+	if Find(loop, func(error) bool { return false }) != nil {
+		t.Errorf(findFailed, "loop", errFoundSpurious)
+	}
+	if Find(nil, func(error) bool { return true }) != nil {
+		t.Errorf(findFailed, "nil", errFoundSpurious)
+	}
 }
@@ -2,22 +2,36 @@ package errors
 
 import (
 	"fmt"
-	"runtime"
+	"reflect"
+
+	"github.com/pkg/errors"
 )
 
 // New makes an Error from the given value. If that value is already an
 // error then it will be used directly, if not, it will be passed to
 // fmt.Errorf("%v"). The stacktrace will point to the line of code that
-// called New.
-func New(e interface{}) *Err {
-	return Wrap(e, 1)
+// called New, unless the Skip option is given.  Options may be given to
+// control how the stacktrace is captured, and to attach a prefix or fields.
+func New(e interface{}, opts ...Option) *Err {
+	return newErr(e, 0, applyOptions(opts))
 }
 
 // Wrap makes an Error from the given value. If that value is already an
 // error then it will be used directly, if not, it will be passed to
 // fmt.Errorf("%v"). The skip parameter indicates how far up the stack
 // to start the stacktrace. 0 is from the current call, 1 from its caller, etc.
+// If the error already carries a stack, as errors from github.com/pkg/errors
+// and github.com/bugsnag/bugsnag-go/errors do, that stack is reported as the
+// deepest nested stack.
 func Wrap(e interface{}, skip int) *Err {
+	return newErr(e, skip, defaultOptions())
+}
+
+// newErr makes an Error from the given value, as described by New, as
+// configured by o.  The skip parameter indicates how far up the stack to
+// start the stacktrace, in addition to o.skip; 0 is from the caller of the
+// function calling newErr, 1 from its caller, etc.
+func newErr(e interface{}, skip int, o options) *Err {
 	var err error
 
 	switch e := e.(type) {
@@ -29,12 +43,95 @@ func Wrap(e interface{}, skip int) *Err {
 		err = fmt.Errorf("%v", e)
 	}
 
-	stack := make([]uintptr, MaxStackDepth)
-	length := runtime.Callers(2+skip, stack[:])
-	return &Err{
-		Underlying: err,
-		stack:      stack[:length],
+	if skip += o.skip; skip < 0 {
+		// the frames of this package are never reported
+		skip = 0
 	}
+	c := newCachedErr()
+	if !o.noStack {
+		c.stack, c.elided, c.elidedAt = captureStack(2+skip, o.depth, o.tailDepth)
+	}
+	c.Underlying = err
+	c.prefix = o.prefix
+	c.fields = addFields(nil, o.fields)
+	c.ignoreNestedStack = o.ignoreNestedStack
+	return c
+}
+
+// stackTracer is implemented by errors from github.com/pkg/errors that carry
+// a stack.
+type stackTracer interface {
+	StackTrace() errors.StackTrace
+}
+
+// errorWithCallers is implemented by errors from
+// github.com/bugsnag/bugsnag-go/errors that carry a stack, and by *Err.
+type errorWithCallers interface {
+	Callers() []uintptr
+}
+
+// adoptStack returns an *Err that adopts the stack of the nearest error in
+// err's chain that carries a stack, if that error is not itself an *Err.
+// That *Err in turn adopts the stack of the nearest error beneath it that
+// carries a stack, and so on, so that the deepest (origin) stack can be
+// found by AssertDeepestUnderlying.  Errors in seen are not adopted again.
+// It is only called once an *Err is asked for its nested stacks, through
+// adopted, rather than whenever an error is wrapped.
+func adoptStack(err error, seen map[error]bool) *Err {
+	switch err.(type) {
+	case nil, *Err:
+		// by far the most common cases
+		return nil
+	}
+
+	carrier := Find(err, func(err error) bool {
+		switch err.(type) {
+		case *ErrNotErr, *ErrUnderlyingNotErr:
+			// the errors of this package's own Assert functions carry the
+			// stack of the *Err they wrap, which is found beneath them
+			return false
+		case *Err, stackTracer, errorWithCallers:
+			return true
+		}
+		return false
+	})
+	if _, ok := carrier.(*Err); ok || carrier == nil {
+		return nil
+	}
+	// a chain may loop back upon itself; uncomparable values cannot, and
+	// cannot be used as map keys anyway
+	if reflect.ValueOf(carrier).Comparable() {
+		if seen[carrier] {
+			return nil
+		}
+		if seen == nil {
+			seen = make(map[error]bool)
+		}
+		seen[carrier] = true
+	}
+
+	var stack []uintptr
+	switch c := carrier.(type) {
+	case errorWithCallers:
+		stack = c.Callers()
+	case stackTracer:
+		for _, frame := range c.StackTrace() {
+			stack = append(stack, uintptr(frame))
+		}
+	}
+
+	adopted := newCachedErr()
+	adopted.Underlying = carrier
+	adopted.stack = stack
+	// the carrier itself would be found again were its origin found lazily
+	adopted.origin.once.Do(func() {
+		for _, u := range unwrapAll(carrier) {
+			if adopted.origin.err = adoptStack(u, seen); adopted.origin.err != nil {
+				break
+			}
+		}
+	})
+	return adopted
 }
 
 // Wrapf makes an Error from the given value.  If that value is already
@@ -44,14 +141,17 @@ func Wrap(e interface{}, skip int) *Err {
 // pameter indicates how far up the stack to start the stacktrace; 0 is from
 // the current call, 1 from its caller, etc.
 func Wrapf(e interface{}, prefixf string, skip int, a ...interface{}) *Err {
-	err := Wrap(e, skip+1)
-	err.prefix = fmt.Sprintf(prefixf, a...)
-	return err
+	o := defaultOptions()
+	o.prefix = fmt.Sprintf(prefixf, a...)
+	return newErr(e, skip, o)
 }
 
 // Errorf creates a new error with the given message. You can use it
 // as a drop-in replacement for fmt.Errorf() to provide descriptive
-// errors in return values.
+// errors in return values.  Operands of any %w verbs in format remain part of
+// the error chain, so that a wrapped *Err's stack is returned as the deepest
+// stack, and RootCause and Is can reach it.  Where several operands are
+// wrapped, the first *Err amongst them provides the deepest stack.
 func Errorf(format string, a ...interface{}) *Err {
-	return Wrap(fmt.Errorf(format, a...), 1)
+	return newErr(fmt.Errorf(format, a...), 0, defaultOptions())
 }
@@ -72,3 +72,20 @@ func ExampleWrapError_skip() {
 		}
 	}()
 }
+
+func ExampleNew_options() {
+	// helper that wraps errors on behalf of its caller
+	wrap := func(err error) error {
+		return New(err,
+			// start the stacktrace from the caller of this helper
+			Skip(1),
+			// capture no more than 10 frames
+			Depth(10),
+			// add context to the error message and as fields
+			Prefix("loading config"),
+			Fields("path", "/etc/app.conf"),
+		)
+	}
+	fmt.Println(wrap(io.ErrUnexpectedEOF))
+	// Output: loading config: unexpected EOF
+}
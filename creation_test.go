@@ -1,10 +1,14 @@
 package errors
 
 import (
+	"bytes"
 	"fmt"
+	"io"
 	"reflect"
 	"strings"
 	"testing"
+
+	pkgerrors "github.com/pkg/errors"
 )
 
 // error strings used in tests
@@ -17,6 +21,8 @@ const (
 	errWrongUnderlyingError = "produces error with the wrong underlying error"
 	errNotMatchWrap         = "the error returned does not match the equivalent returned by Wrap()"
 	errSkipFailed           = "failed to successfully skip parts of the stack"
+	errWrappedNotReached    = "the error wrapped with %w was not reached"
+	errOriginNotReported    = "the origin stack of the wrapped error was not reported"
 )
 
 // constructor failed format strings used in tests
@@ -271,3 +277,203 @@ func TestWrapfError(t *testing.T) {
 		t.Errorf(constructorStringFailed, errSkipFailed)
 	}
 }
+
+func TestErrorfWrapping(t *testing.T) {
+	// test case: a single *Err wrapped with %w
+	inner := Wrapf(io.EOF, testPrefixFoobar, 0)
+	err := Errorf("loading %s: %w", testFormatArgumentBaz, inner)
+	if err.Error() != "loading "+testFormatArgumentBaz+": "+inner.Error() {
+		t.Errorf(constructorErrorFailed, errWrongErrorMessage)
+	}
+	if u, _ := AssertDeepestUnderlying(err); u != inner {
+		t.Errorf(constructorErrorFailed, errWrappedNotReached)
+	}
+	if !bytes.Equal(err.Stack(), inner.ParentStack()) || !reflect.DeepEqual(err.Callers(), inner.ParentCallers()) {
+		t.Errorf(constructorErrorFailed, errStacksNotMatch)
+	}
+	if err.RootCause() != io.EOF {
+		t.Errorf(constructorErrorFailed, errWrappedNotReached)
+	}
+	if !Is(err, inner) || !Is(err, io.EOF) {
+		t.Errorf(constructorErrorFailed, errWrappedNotReached)
+	}
+
+	// test case: several operands wrapped with %w, the first of which is not
+	// an *Err
+	inner2 := New(io.ErrUnexpectedEOF)
+	err = Errorf("%w, %w and %w", io.ErrClosedPipe, inner, inner2)
+	if u, _ := AssertDeepestUnderlying(err); u != inner {
+		t.Errorf(constructorErrorFailed, errWrappedNotReached)
+	}
+	if !Is(err, io.ErrClosedPipe) || !Is(err, inner2) || !Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf(constructorErrorFailed, errWrappedNotReached)
+	}
+
+	// test case: no *Err wrapped with %w
+	err = Errorf("loading: %w", io.EOF)
+	if u, _ := AssertDeepestUnderlying(err); u != err {
+		t.Errorf(constructorPlainErrorFailed, errWrongUnderlyingError)
+	}
+	if !Is(err, io.EOF) {
+		t.Errorf(constructorPlainErrorFailed, errWrappedNotReached)
+	}
+}
+
+// callersErr carries a stack in the same way as errors from
+// github.com/bugsnag/bugsnag-go/errors do
+type callersErr struct {
+	stack []uintptr
+}
+
+func (e *callersErr) Error() string      { return testMsgFoo }
+func (e *callersErr) Callers() []uintptr { return e.stack }
+
+// the following are not inlined, so that they appear in the stacks they
+// capture
+
+//go:noinline
+func newPkgError() error {
+	return pkgerrors.New(testMsgFoo)
+}
+
+//go:noinline
+func newCallersError() error {
+	return &callersErr{stack: callers()}
+}
+
+func TestWrapForeignStack(t *testing.T) {
+	// test case: error from pkg/errors, wrapped by pkg/errors
+	origin := newPkgError()
+	err := Wrap(pkgerrors.Wrap(origin, testPrefixFoobar), 0)
+	if err.StackFrames()[0].Name != "newPkgError" {
+		t.Errorf(constructorErrorFailed, errOriginNotReported)
+	}
+	if err.Callers()[0] != uintptr(origin.(stackTracer).StackTrace()[0]) {
+		t.Errorf(constructorErrorFailed, errOriginNotReported)
+	}
+	if err.RootCause() != origin || !Is(err, origin) {
+		t.Errorf(constructorErrorFailed, errWrongUnderlyingError)
+	}
+	if !reflect.DeepEqual(err.SetIgnoreNestedStack(true).Callers(), err.ParentCallers()) {
+		t.Errorf(constructorErrorFailed, errStacksNotMatch)
+	}
+
+	// test case: error carrying callers, wrapped by %w
+	origin = newCallersError()
+	err = New(Errorf("%v: %w", testPrefixFoobar, origin))
+	if err.StackFrames()[0].Name != "newCallersError" {
+		t.Errorf(constructorErrorFailed, errOriginNotReported)
+	}
+	if !reflect.DeepEqual(err.Callers(), origin.(errorWithCallers).Callers()) {
+		t.Errorf(constructorErrorFailed, errOriginNotReported)
+	}
+
+	// test case: *Err beneath an error from pkg/errors is deeper still
+	inner := New(io.EOF)
+	err = New(pkgerrors.Wrap(inner, testPrefixFoobar))
+	if u, _ := AssertDeepestUnderlying(err); u != inner {
+		t.Errorf(constructorErrorFailed, errWrongUnderlyingError)
+	}
+
+	// test case: error from pkg/errors that carries no stack
+	err = New(pkgerrors.WithMessage(io.EOF, testPrefixFoobar))
+	if u, _ := AssertDeepestUnderlying(err); u != err {
+		t.Errorf(constructorPlainErrorFailed, errWrongUnderlyingError)
+	}
+}
+
+// countingCallersErr carries a stack, and counts how many times it was asked
+// for it
+type countingCallersErr struct {
+	callersErr
+	calls int
+}
+
+func (e *countingCallersErr) Callers() []uintptr {
+	e.calls++
+	return e.stack
+}
+
+func TestWrapAdoptsLazily(t *testing.T) {
+	origin := &countingCallersErr{callersErr: callersErr{stack: callers()}}
+	err := Wrap(origin, 0)
+	if origin.calls != 0 {
+		t.Errorf(constructorErrorFailed, "the stack was adopted before it was asked for")
+	}
+	if !reflect.DeepEqual(err.Callers(), origin.stack) {
+		t.Errorf(constructorErrorFailed, errOriginNotReported)
+	}
+	err.StackFrames()
+	err.SetIgnoreNestedStack(false).Callers()
+	if origin.calls != 1 {
+		t.Errorf(constructorErrorFailed, "the stack was adopted more than once")
+	}
+}
+
+func TestWrapAssertError(t *testing.T) {
+	_, assertErr := AssertUnderlying(io.EOF)
+	err := Wrap(fmt.Errorf("%w", assertErr), 0)
+	if u, _ := AssertDeepestUnderlying(err); u.Underlying == assertErr {
+		t.Errorf(constructorErrorFailed, "the error of an Assert function was adopted as carrying a stack")
+	}
+}
+
+// benchmarkDepth is how deep into a recursive call the benchmarks below make
+// their errors, so that the stacks captured are of a realistic depth
+const benchmarkDepth = 8
+
+// atDepth calls f from n nested calls deeper than its caller
+func atDepth(n int, f func()) {
+	if n == 0 {
+		f()
+		return
+	}
+	atDepth(n-1, f)
+}
+
+func BenchmarkNew(b *testing.B) {
+	atDepth(benchmarkDepth, func() {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_ = New(io.EOF)
+		}
+	})
+}
+
+func BenchmarkWrap(b *testing.B) {
+	err := New(io.EOF)
+	atDepth(benchmarkDepth, func() {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_ = Wrap(err, 0)
+		}
+	})
+}
+
+func BenchmarkWrapf(b *testing.B) {
+	err := New(io.EOF)
+	atDepth(benchmarkDepth, func() {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_ = Wrapf(err, testFormatPrefixFoobar, 0, testFormatArgumentBaz)
+		}
+	})
+}
+
+func BenchmarkErrorf(b *testing.B) {
+	atDepth(benchmarkDepth, func() {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_ = Errorf(testFormatPrefixFoobar, testFormatArgumentBaz)
+		}
+	})
+}
+
+func BenchmarkNewNoStack(b *testing.B) {
+	atDepth(benchmarkDepth, func() {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_ = New(io.EOF, NoStack())
+		}
+	})
+}
@@ -15,7 +15,9 @@ package errors
 import (
 	"bytes"
 	"fmt"
+	"io"
 	"reflect"
+	"sync"
 
 	"github.com/pkg/errors"
 )
@@ -31,22 +33,41 @@ type Err struct {
 	Underlying error
 	// program counters
 	stack []uintptr
-	// cache of parsed stack
-	frames []StackFrame
+	// the number of program counters elided from stack, as it was too deep
+	// to be captured whole, and the index in stack at which they were elided
+	elided, elidedAt int
+	// cache of parsed stack, shared by copies of this *Err
+	frames *frameCache
 	// a prefix to prepend to the error message of the underlying error
 	prefix string
 	// whether to return the deepest nested stacktrace (false) or the shallowest
 	// (this instance's) stacktrace
 	ignoreNestedStack bool
+	// cache of the *Err adopting the stack of a nested error that is not an
+	// *Err, but carries a stack of its own, shared by copies of this *Err
+	origin *originCache
+	// structured key/value context attached to this *Err
+	fields map[string]interface{}
 }
 
-// SetIgnoreNestedStack sets the ignoreNestedSTack field on the *Err this
-// is called on, which determines whether functions that return information
-// about the stack, return it of the stack of this *Err (true), or of the
-// deepest nested *Err (false; default).
+// SetIgnoreNestedStack returns a copy of the *Err this is called on, with
+// the ignoreNestedStack field set, which determines whether functions that
+// return information about the stack, return it of the stack of this *Err
+// (true), or of the deepest nested *Err (false; default).  The *Err this is
+// called on is left unmodified, so this is safe to call on an *Err that is
+// shared, such as a package level sentinel.
 func (err *Err) SetIgnoreNestedStack(val bool) *Err {
-	err.ignoreNestedStack = val
-	return err
+	c := err.clone()
+	c.ignoreNestedStack = val
+	return c
+}
+
+// clone returns a shallow copy of the *Err this is called on, that shares its
+// underlying error, stack and frame cache, for use by setters that must not
+// modify the original.
+func (err *Err) clone() *Err {
+	c := *err
+	return &c
 }
 
 // Error returns the underlying error's message.
@@ -66,8 +87,35 @@ func (err *Err) Error() string {
 	return msg
 }
 
+// Format implements fmt.Formatter.  The %s and %v verbs print the error
+// message, and %q prints it quoted.  The %+v verb prints every *Err in the
+// chain, starting with err, in the same form as ParentErrorStack, so that
+// each layer's prefix is shown alongside its own stack.  Nested *Err are not
+// printed beyond a layer that has ignoreNestedStack set, so %+v of an *Err
+// with ignoreNestedStack set prints only that *Err's stack.
+func (err *Err) Format(s fmt.State, verb rune) {
+	switch verb {
+	case 'v':
+		if s.Flag('+') {
+			for _, e := range err.layers() {
+				io.WriteString(s, e.ParentErrorStack())
+				if e.ignoreNestedStack {
+					break
+				}
+			}
+			return
+		}
+		io.WriteString(s, err.Error())
+	case 's':
+		io.WriteString(s, err.Error())
+	case 'q':
+		fmt.Fprintf(s, "%q", err.Error())
+	}
+}
+
 // Stack returns the callstack formatted the same way that go does
-// in runtime/debug.Stack().  Note that this function will return
+// in runtime/debug.Stack(), with repeated frames collapsed as by
+// Stack.Collapsed.  Note that this function will return
 // a formatted callstack of the deepest nested *Err instance, unless
 // ignoreNestedStack is set on the *Err.
 func (err *Err) Stack() []byte {
@@ -83,15 +131,14 @@ func (err *Err) Stack() []byte {
 }
 
 // ParentStack returns the callstack of the parent error, formatted the same
-// way that go does in runtime/debug.Stack().  Note that this function will
+// way that go does in runtime/debug.Stack(), with repeated frames collapsed
+// as by Stack.Collapsed.  Note that this function will
 // return a formatted callstack of the actual *Err this is called upon, not
 // that of the deepest nested *Err.
 func (err *Err) ParentStack() []byte {
 	buf := bytes.Buffer{}
 
-	for _, frame := range err.ParentStackFrames() {
-		buf.WriteString(frame.String())
-	}
+	err.ParentStackFrames().Collapsed().writeTo(&buf)
 
 	return buf.Bytes()
 }
@@ -99,14 +146,17 @@ func (err *Err) ParentStack() []byte {
 // ParentCallers satisfies the bugsnag ErrorWithCallerS() interface
 // so that the stack can be read out.  It returns the stack of the *Err
 // that this function is called on, rather than that of the deepes nested
-// *Err.
+// *Err.  Program counters elided from a stack too deep to be captured whole
+// are left out, with no marker in their place; ParentElided reports where.
 func (err *Err) ParentCallers() []uintptr {
 	return err.stack
 }
 
 // Callers satisfies the bugsnag ErrorWithCallers() interface so that the stack
 // can be read out.  It returns the stack of the deepest nested *Err, unless
-// ignoreNestedStack is set on the *Err.
+// ignoreNestedStack is set on the *Err.  Program counters elided from a stack
+// too deep to be captured whole are left out, with no marker in their place;
+// Elided reports where.
 func (err *Err) Callers() []uintptr {
 	if !err.ignoreNestedStack {
 		u, _ := AssertDeepestUnderlying(err)
@@ -119,39 +169,59 @@ func (err *Err) Callers() []uintptr {
 	return err.ParentCallers()
 }
 
+// ParentElided returns the number of program counters elided from the stack
+// of the *Err this is called on, as it was too deep to be captured whole, and
+// the index in ParentCallers, and in ParentStackTrace, at which they were
+// elided.  It returns 0, 0 if the stack was captured whole.
+func (err *Err) ParentElided() (elided, at int) {
+	return err.elided, err.elidedAt
+}
+
+// Elided returns the number of program counters elided from the stack
+// returned by Callers, and by StackTrace, and the index in it at which they
+// were elided, in the manner of ParentElided.
+func (err *Err) Elided() (elided, at int) {
+	if !err.ignoreNestedStack {
+		u, _ := AssertDeepestUnderlying(err)
+		return u.ParentElided()
+	}
+	return err.ParentElided()
+}
+
 // ErrorStack returns a string that contains both the
 // error message and the callstack.  The callstack is that of the deepest
 // nested *Err, rather than that of the *Err this is called on, unless
-// ignoreNestedStack is set on the *Err.
+// ignoreNestedStack is set on the *Err.  Any fields attached to the chain of
+// nested *Err, as returned by Fields, are included after the error message.
 func (err *Err) ErrorStack() string {
-	return err.TypeName() + " " + err.Error() + "\n" + string(err.Stack())
+	return err.TypeName() + " " + err.Error() + "\n" + formatFields(err.Fields()) + string(err.Stack())
 }
 
 // ParentErrorStack returns a string that contains both the error message and
 // the callstack.  The callstack is that of the *Err this is called on, rather
-// than the deepest nested *Err.
+// than the deepest nested *Err.  Any fields attached to the *Err this is
+// called on are included after the error message.
 func (err *Err) ParentErrorStack() string {
-	return err.TypeName() + " " + err.Error() + "\n" + string(err.ParentStack())
+	return err.TypeName() + " " + err.Error() + "\n" + formatFields(err.fields) + string(err.ParentStack())
 }
 
-// ParentStackFrames returns an array of frames containing information about
-// the stack of the *Err this is called on.
-func (err *Err) ParentStackFrames() []StackFrame {
+// ParentStackFrames returns the Stack of frames containing information about
+// the stack of the *Err this is called on.  The stack is resolved into frames
+// only once, even when this is called from several goroutines at once, and
+// the Stack returned must not be modified.
+func (err *Err) ParentStackFrames() Stack {
 	if err.frames == nil {
-		err.frames = make([]StackFrame, len(err.stack))
-
-		for i, pc := range err.stack {
-			err.frames[i] = NewStackFrame(pc)
-		}
+		// an *Err not made by this package has nowhere to cache its frames
+		return newFrameCache().resolve(err)
 	}
-
-	return err.frames
+	return err.frames.resolve(err)
 }
 
-// StackFrames returns an array of frames containing information about the
+// StackFrames returns the Stack of frames containing information about the
 // stack of the deepest nested *Err, unless ignoreNestedStack is set on the
 // *Err, in which case it is about the stack of the *Err this is called on.
-func (err *Err) StackFrames() []StackFrame {
+// The Stack returned must not be modified.
+func (err *Err) StackFrames() Stack {
 	if !err.ignoreNestedStack {
 		u, _ := AssertDeepestUnderlying(err)
 		// we ignore the error of the above function for brevity, because an error
@@ -166,7 +236,8 @@ func (err *Err) StackFrames() []StackFrame {
 // ParentStackTrace implements a function similar that required for the
 // pkg/errors.stacktracer interface.  It returns
 // an array of frames containing information about the stack of the *Err this
-// is called on, rather than the deepest nested *Err.
+// is called on, rather than the deepest nested *Err.  Frames elided from a
+// stack too deep to be captured whole are left out, as by ParentCallers.
 func (err *Err) ParentStackTrace() errors.StackTrace {
 	st := make(errors.StackTrace, len(err.stack))
 
@@ -180,7 +251,8 @@ func (err *Err) ParentStackTrace() errors.StackTrace {
 // StackTrace implements the pkg/errors.stacktracer interface.  It returns an
 // array of frames containing information about the stack of the deepest nested
 // *Err, unless ignoreNestedStack is set on the *Err, in which case it is
-// about the stack of the *Err this is called on.
+// about the stack of the *Err this is called on.  Frames elided from a stack
+// too deep to be captured whole are left out, as by Callers.
 func (err *Err) StackTrace() errors.StackTrace {
 	if !err.ignoreNestedStack {
 		u, _ := AssertDeepestUnderlying(err)
@@ -193,10 +265,21 @@ func (err *Err) StackTrace() errors.StackTrace {
 	return err.ParentStackTrace()
 }
 
+// nilType is the type name reported by TypeName for an *Err whose
+// underlying error is nil.
+const nilType = "<nil>"
+
 // TypeName returns the type this error. e.g. *errors.stringError.
 func (err *Err) TypeName() string {
-	if _, ok := err.Underlying.(uncaughtPanic); ok {
+	switch u := err.Underlying.(type) {
+	case nil:
+		return nilType
+	case *Panic:
 		return "panic"
+	case *FatalError:
+		return "fatal error"
+	case *decodedErr:
+		return u.typeName
 	}
 	return reflect.TypeOf(err.Underlying).String()
 }
@@ -207,16 +290,137 @@ func (err *Err) Cause() error {
 	return err.Underlying
 }
 
-// RootCause returns the root underlying cause of an error.  It returns the
-// first error in the stack of nested errors, that is not of type *Err
-func (err *Err) RootCause() (root error) {
-	root = err
+// Unwrap returns the underlying error of an *Err, so that the standard
+// library's errors.Is, errors.As and errors.Unwrap can see through it.  Any
+// prefix added by Wrapf, and the value of ignoreNestedStack, have no bearing
+// on the error returned.
+func (err *Err) Unwrap() error {
+	return err.Underlying
+}
+
+// Is is the hook used by the standard library's errors.Is.  It reports
+// whether target is an *Err that wraps the same root cause as err, so that
+// errors.Is(New(io.EOF), New(io.EOF)) agrees with this package's Is.
+func (err *Err) Is(target error) bool {
+	t, ok := Assert(target)
+	if !ok {
+		return false
+	}
+	// t.RootCause() is never an *Err, so this cannot recurse back into Is
+	return Is(err.Underlying, t.RootCause())
+}
+
+// As is the hook used by the standard library's errors.As.  It allows types
+// that embed an *Err, such as ErrNotErr, to yield that *Err when the target
+// is a **Err.
+func (err *Err) As(target interface{}) bool {
+	if t, ok := target.(**Err); ok {
+		*t = err
+		return true
+	}
+	return false
+}
+
+// frameCache caches the frames of a stack, which are resolved exactly once
+// and then shared, so that an *Err may be used from several goroutines.
+type frameCache struct {
+	once   sync.Once
+	frames []StackFrame
+}
+
+// newFrameCache returns a frameCache that will resolve frames when first
+// asked to.
+func newFrameCache() *frameCache {
+	return &frameCache{}
+}
+
+// originCache caches the *Err adopting the stack of a nested error that is
+// not an *Err, as returned by adoptStack, which is found exactly once, and
+// only when first asked for, as most stacks are never looked at.
+type originCache struct {
+	once sync.Once
+	err  *Err
+}
+
+// cachedErr holds an *Err together with its caches, so that they can be
+// allocated at once.
+type cachedErr struct {
+	err    Err
+	cache  frameCache
+	origin originCache
+}
+
+// newCachedErr returns an empty *Err, with a frameCache that will resolve
+// its frames, and an originCache that will find its origin, when first
+// asked to.
+func newCachedErr() *Err {
+	c := &cachedErr{}
+	c.err.frames = &c.cache
+	c.err.origin = &c.origin
+	return &c.err
+}
+
+// adopted returns the *Err adopting the stack of the nearest error in the
+// chain of err's underlying error that carries a stack, if that error is not
+// itself an *Err, as described by adoptStack.
+func (err *Err) adopted() *Err {
+	if err.origin == nil {
+		// an *Err not made by this package has nowhere to cache its origin
+		return adoptStack(err.Underlying, nil)
+	}
+	err.origin.once.Do(func() {
+		err.origin.err = adoptStack(err.Underlying, nil)
+	})
+	return err.origin.err
+}
+
+// resolvedFrameCache returns a frameCache that holds the given frames, such
+// as those of a parsed panic, that do not need to be resolved.
+func resolvedFrameCache(frames []StackFrame) *frameCache {
+	c := &frameCache{frames: frames}
+	c.once.Do(func() {})
+	return c
+}
+
+// resolve returns the frames of the stack of err, resolving them if this is
+// the first time resolve has been called.
+func (c *frameCache) resolve(err *Err) []StackFrame {
+	c.once.Do(func() {
+		if err.elided == 0 {
+			c.frames = newStackFrames(err.stack)
+			return
+		}
+		c.frames = newStackFrames(err.stack[:err.elidedAt])
+		c.frames = append(c.frames, StackFrame{Elided: err.elided})
+		c.frames = append(c.frames, newStackFrames(err.stack[err.elidedAt:])...)
+	})
+	return c.frames
+}
+
+// layers returns the chain of nested *Err, starting with err and ending with
+// the deepest nested *Err, as followed by AssertUnderlying.
+func (err *Err) layers() []*Err {
+	layers := []*Err{err}
+	// a chain may loop back upon itself through a non-*Err wrapper
+	seen := map[*Err]bool{err: true}
 	for {
-		e, ok := Assert(root)
-		if !ok {
-			break
+		u, ok := layers[len(layers)-1].underlying()
+		if !ok || seen[u] {
+			return layers
 		}
-		root = e.Cause()
+		seen[u] = true
+		layers = append(layers, u)
 	}
-	return root
+}
+
+// RootCause returns the root underlying cause of an error.  It returns the
+// underlying error of the deepest nested *Err, which is the first error in
+// the stack of nested errors that is not of type *Err.  *Err wrapped by
+// other errors, such as those passed to Errorf with a %w verb, are included
+// in the stack of nested errors.
+func (err *Err) RootCause() error {
+	u, _ := AssertDeepestUnderlying(err)
+	// we ignore the error of the above function, because an error is never
+	// returned from it when it is passed an *Err
+	return u.Underlying
 }
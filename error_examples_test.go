@@ -1,6 +1,9 @@
 package errors
 
-import "fmt"
+import (
+	"fmt"
+	"io"
+)
 
 func ExampleError_Error() {
 	// example error returning function
@@ -37,3 +40,12 @@ func ExampleError_StackFrames() {
 		fmt.Println(frame.File, frame.LineNumber, frame.Package, frame.Name)
 	}
 }
+
+func ExampleErr_Format() {
+	// example *Err returning function
+	err := func() *Err { return Wrapf(io.EOF, "reading header", 0) }()
+	// print the message, quoted
+	fmt.Printf("%q\n", err)
+	// print the message, followed by the stack of every layer
+	fmt.Printf("%+v", err)
+}
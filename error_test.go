@@ -2,10 +2,14 @@ package errors
 
 import (
 	"bytes"
+	stderrors "errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"reflect"
 	"runtime"
 	"strings"
+	"sync"
 	"testing"
 )
 
@@ -15,12 +19,21 @@ const (
 	errNotContainStack            = "does not contain appropriate stack"
 	errNotContainType             = "does not contain appropriate error type"
 	errIgnoreNestedStackIncorrect = "the value of the ignoreNestedStack field is incorrect"
+	errUnwrapIncorrect            = "returned unwrapped error is not correct"
+	errStdlibNotSeeThrough        = "the standard library could not see through the *Err"
+	errStdlibSawThrough           = "the standard library matched an error it should not have"
+	errFormatIncorrect            = "the formatted output is not correct"
+	errFramesResolvedTwice        = "the stack was resolved into frames more than once"
 )
 
 // error format strings used by this file
 const (
 	parentErrorStackFailed     = ".ParentErrorStack() failed; %v"
 	setIgnoreNestedStackFailed = ".SetIgnoreNestedStack() failed; %v"
+	unwrapFailed               = ".Unwrap() failed; %v"
+	stdlibIsFailed             = "errors.Is() failed; %v"
+	stdlibAsFailed             = "errors.As() failed; %v"
+	formatFailed               = "formatting with %v failed; %v"
 )
 
 func TestSetIgnoreNestedStack(t *testing.T) {
@@ -40,6 +53,37 @@ func TestSetIgnoreNestedStack(t *testing.T) {
 	if e2.SetIgnoreNestedStack(false).ignoreNestedStack != false {
 		t.Errorf(setIgnoreNestedStackFailed, errIgnoreNestedStackIncorrect)
 	}
+
+	// the *Err called on is left unmodified, and the copy shares its chain
+	if e.ignoreNestedStack != false || e2.ignoreNestedStack != true {
+		t.Errorf(setIgnoreNestedStackFailed, errIgnoreNestedStackIncorrect)
+	}
+	nested := New(e)
+	c := nested.SetIgnoreNestedStack(true)
+	if c == nested || c.Underlying != nested.Underlying || !reflect.DeepEqual(c.ParentCallers(), nested.ParentCallers()) {
+		t.Errorf(setIgnoreNestedStackFailed, errWrongUnderlyingError)
+	}
+}
+
+func TestSharedSentinelImmutable(t *testing.T) {
+	// a sentinel shared between several holders
+	sentinel := New(Errorf("sentinel: %w", New(io.EOF)))
+	stack, errorStack := string(sentinel.Stack()), sentinel.ErrorStack()
+
+	// one holder changing its options does not affect any other
+	_ = sentinel.SetIgnoreNestedStack(true)
+	_ = sentinel.With("holder", 1)
+	if string(sentinel.Stack()) != stack || sentinel.ErrorStack() != errorStack || len(sentinel.Fields()) != 0 {
+		t.Errorf(setIgnoreNestedStackFailed, errIgnoreNestedStackIncorrect)
+	}
+
+	// nor does one changing the options of an ErrNotErr
+	_, notErr := AssertUnderlying(io.EOF)
+	embedded := notErr.(*ErrNotErr).Err
+	_ = notErr.(*ErrNotErr).SetIgnoreNestedStack(true).With("holder", 1)
+	if embedded != notErr.(*ErrNotErr).Err || embedded.ignoreNestedStack || len(embedded.Fields()) != 0 {
+		t.Errorf(setIgnoreNestedStackFailed, errIgnoreNestedStackIncorrect)
+	}
 }
 
 func TestCallers(t *testing.T) {
@@ -129,6 +173,147 @@ func TestRootCause(t *testing.T) {
 	}
 }
 
+func TestUnwrap(t *testing.T) {
+	// test case: *Err with underlying nil error
+	if New(nil).Unwrap() != nil {
+		t.Errorf(unwrapFailed, errUnwrapIncorrect)
+	}
+
+	// test case: *Err with underlying plain error
+	if New(io.EOF).Unwrap() != io.EOF {
+		t.Errorf(unwrapFailed, errUnwrapIncorrect)
+	}
+
+	// test case: *Err with underlying *Err, unwrapped by the standard library
+	underlying := New(io.EOF)
+	if stderrors.Unwrap(Wrapf(underlying, testPrefixFoobar, 0)) != underlying {
+		t.Errorf(unwrapFailed, errUnwrapIncorrect)
+	}
+}
+
+func TestStdlibIs(t *testing.T) {
+	// test case: sentinels nested beneath several *Err, with and without
+	// prefixes and ignoreNestedStack
+	if !stderrors.Is(New(New(io.EOF)), io.EOF) {
+		t.Errorf(stdlibIsFailed, errStdlibNotSeeThrough)
+	}
+	if !stderrors.Is(Wrapf(Wrapf(fs.ErrNotExist, testPrefixFoobar, 0), testPrefixFoobar, 0), fs.ErrNotExist) {
+		t.Errorf(stdlibIsFailed, errStdlibNotSeeThrough)
+	}
+	if !stderrors.Is(New(New(io.EOF)).SetIgnoreNestedStack(true), io.EOF) {
+		t.Errorf(stdlibIsFailed, errStdlibNotSeeThrough)
+	}
+	if stderrors.Is(New(New(io.EOF)), io.ErrUnexpectedEOF) {
+		t.Errorf(stdlibIsFailed, errStdlibSawThrough)
+	}
+
+	// test case: *Err wrapped by a standard library wrapper
+	if !stderrors.Is(fmt.Errorf("%w", New(io.EOF)), io.EOF) {
+		t.Errorf(stdlibIsFailed, errStdlibNotSeeThrough)
+	}
+
+	// test case: two distinct *Err wrapping the same root cause
+	if !stderrors.Is(New(New(io.EOF)), Wrapf(io.EOF, testPrefixFoobar, 0)) {
+		t.Errorf(stdlibIsFailed, errStdlibNotSeeThrough)
+	}
+	if stderrors.Is(New(io.EOF), New(io.ErrUnexpectedEOF)) {
+		t.Errorf(stdlibIsFailed, errStdlibSawThrough)
+	}
+}
+
+func TestStdlibAs(t *testing.T) {
+	// test case: *PathError beneath a *Err with a prefix
+	underlying := &fs.PathError{Op: "open", Path: "/nonexistent", Err: fs.ErrNotExist}
+	var pathErr *fs.PathError
+	if !stderrors.As(Wrapf(New(underlying), testPrefixFoobar, 0), &pathErr) || pathErr != underlying {
+		t.Errorf(stdlibAsFailed, errStdlibNotSeeThrough)
+	}
+
+	// test case: *Err beneath a standard library wrapper
+	inner := New(io.EOF)
+	var e *Err
+	if !stderrors.As(fmt.Errorf("%w", inner), &e) || e != inner {
+		t.Errorf(stdlibAsFailed, errStdlibNotSeeThrough)
+	}
+
+	// test case: *Err embedded in ErrNotErr
+	_, notErr := AssertUnderlying(io.EOF)
+	e = nil
+	if !stderrors.As(notErr, &e) || e != notErr.(*ErrNotErr).Err {
+		t.Errorf(stdlibAsFailed, errStdlibNotSeeThrough)
+	}
+
+	// test case: no *Err present
+	e = nil
+	if stderrors.As(io.EOF, &e) {
+		t.Errorf(stdlibAsFailed, errStdlibSawThrough)
+	}
+}
+
+func TestFormat(t *testing.T) {
+	inner := Wrapf(io.EOF, testPrefixFoobar, 0)
+	outer := Wrapf(inner, testFormatPrefixFoobar, 0, testFormatArgumentBaz)
+
+	// test case: verbs that print the message only
+	for _, verb := range []string{"%s", "%v"} {
+		if fmt.Sprintf(verb, outer) != outer.Error() {
+			t.Errorf(formatFailed, verb, errFormatIncorrect)
+		}
+	}
+	if fmt.Sprintf("%q", outer) != fmt.Sprintf("%q", outer.Error()) {
+		t.Errorf(formatFailed, "%q", errFormatIncorrect)
+	}
+
+	// test case: %+v prints every layer with its prefix and stack
+	if fmt.Sprintf("%+v", outer) != outer.ParentErrorStack()+inner.ParentErrorStack() {
+		t.Errorf(formatFailed, "%+v", errFormatIncorrect)
+	}
+	if fmt.Sprintf("%+v", New(io.EOF)) == New(io.EOF).Error() {
+		t.Errorf(formatFailed, "%+v", errNotContainStack)
+	}
+
+	// test case: %+v stops at a layer with ignoreNestedStack set
+	ignoring := Wrapf(inner, testPrefixFoobar, 0).SetIgnoreNestedStack(true)
+	if fmt.Sprintf("%+v", ignoring) != ignoring.ErrorStack() {
+		t.Errorf(formatFailed, "%+v", errFormatIncorrect)
+	}
+	wrapped := New(ignoring)
+	if fmt.Sprintf("%+v", wrapped) != wrapped.ParentErrorStack()+ignoring.ErrorStack() {
+		t.Errorf(formatFailed, "%+v", errFormatIncorrect)
+	}
+}
+
+// sharedErr is shared by every goroutine in TestConcurrentStack, in the same
+// way as a package level sentinel would be
+var sharedErr = Errorf("shared: %w", New(io.EOF))
+
+func TestConcurrentStack(t *testing.T) {
+	// run with -race to detect unsynchronised access to the frame cache
+	const goroutines = 16
+	var wg sync.WaitGroup
+	frames := make([][]StackFrame, goroutines)
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_ = sharedErr.Stack()
+			_ = sharedErr.StackTrace()
+			_ = sharedErr.ErrorStack()
+			_ = sharedErr.ParentErrorStack()
+			_ = fmt.Sprintf("%+v", sharedErr)
+			frames[i] = sharedErr.StackFrames()
+		}(i)
+	}
+	wg.Wait()
+
+	// every goroutine got the frames resolved by the same, single resolution
+	for i := 1; i < goroutines; i++ {
+		if &frames[i][0] != &frames[0][0] {
+			t.Errorf(constructorErrorFailed, errFramesResolvedTwice)
+		}
+	}
+}
+
 func TestParentErrorStack(t *testing.T) {
 	// test error to use; Error constructor
 	e := New(New(New(testMsgFoo)))
@@ -247,3 +432,25 @@ func callersToFrames(callers []uintptr) []runtime.Frame {
 		}
 	}
 }
+
+func BenchmarkErrorStack(b *testing.B) {
+	var err *Err
+	atDepth(benchmarkDepth, func() {
+		err = Wrapf(New(io.EOF), testPrefixFoobar, 0)
+	})
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_ = err.ErrorStack()
+	}
+}
+
+func BenchmarkFields(b *testing.B) {
+	var err *Err
+	atDepth(benchmarkDepth, func() {
+		err = Wrapf(New(io.EOF).With("user_id", 42), testPrefixFoobar, 0).With("shard", 3)
+	})
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_ = err.Fields()
+	}
+}
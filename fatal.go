@@ -0,0 +1,72 @@
+package errors
+
+import (
+	"strings"
+)
+
+// A FatalError is the underlying error of an *Err parsed by ParsePanic from
+// the output of a go program that crashed with a fatal error, which unlike a
+// panic cannot be recovered from, such as "fatal error: concurrent map
+// writes" or "fatal error: all goroutines are asleep - deadlock!".  The
+// TypeName of such an *Err is "fatal error".
+type FatalError struct {
+	// The Message of the fatal error, such as "concurrent map writes"
+	Message string
+	// The Details of the fatal error that go printed on lines of their own
+	// before it, without their "runtime: " prefix, such as "out of memory:
+	// cannot allocate 1099511627776-byte block (3932160 in use)"
+	Details []string
+	// The Signal that caused the fatal error, if the runtime caught one, as
+	// for "fatal error: unexpected signal during runtime execution"
+	Signal *Signal
+	// The Goroutines printed with the fatal error, in the order in which
+	// they were printed.  Go prints only the goroutine that crashed for some
+	// fatal errors, and every goroutine for others, such as a deadlock.
+	Goroutines []Goroutine
+}
+
+func (e *FatalError) Error() string {
+	return e.Message
+}
+
+// parseFatalError parses the output of a go program that crashed with a
+// fatal error, whose message is on lines[i] and whose details are on the
+// lines before it.  The stack of the *Err returned is that of the goroutine
+// that was running when the program crashed, or, where none was, as when
+// every goroutine is deadlocked, that of the first goroutine printed, which
+// is the main goroutine.
+func parseFatalError(lines []string, i int) (*Err, error) {
+	goroutines, err := parseGoroutines(lines[i+1:])
+	if err != nil {
+		return nil, err
+	}
+	e := &FatalError{Message: strings.TrimPrefix(lines[i], "fatal error: "), Goroutines: goroutines}
+	for _, line := range lines[:i] {
+		e.Details = append(e.Details, strings.TrimPrefix(line, "runtime: "))
+	}
+	if i+1 < len(lines) {
+		e.Signal, _ = parseSignal(lines[i+1])
+	}
+
+	var crashed *Goroutine
+	for j := range goroutines {
+		if strings.HasPrefix(goroutines[j].State, "running") {
+			crashed = &goroutines[j]
+			break
+		}
+	}
+	if crashed == nil && len(goroutines) > 0 {
+		crashed = &goroutines[0]
+	}
+
+	var stack Stack
+	if crashed != nil {
+		stack = crashed.Stack
+		if crashed.Creator != nil {
+			// the stack is shared with the goroutine, so is copied rather
+			// than appended to
+			stack = append(stack[:len(stack):len(stack)], *crashed.Creator)
+		}
+	}
+	return &Err{Underlying: e, frames: resolvedFrameCache(stack)}, nil
+}
@@ -0,0 +1,102 @@
+package errors
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// With returns a copy of the *Err this is called on, with structured
+// context attached.  The arguments are alternating keys and values, such as
+// With("user_id", id, "shard", 3).  Keys that are not strings are converted
+// to strings with fmt.Sprint, and a key without a value is given a nil value.
+// Values for keys that are already attached are replaced in the copy.  The
+// *Err this is called on is left unmodified.
+func (err *Err) With(keyvals ...interface{}) *Err {
+	if len(keyvals) == 0 {
+		return err
+	}
+	c := err.clone()
+	c.fields = addFields(err.fields, keyvals)
+	return c
+}
+
+// addFields returns a new map holding the given fields, with the
+// alternating keys and values in keyvals attached as described by With; or
+// nil if there are no fields at all.
+func addFields(fields map[string]interface{}, keyvals []interface{}) map[string]interface{} {
+	if len(fields) == 0 && len(keyvals) == 0 {
+		return nil
+	}
+	added := make(map[string]interface{}, len(fields)+(len(keyvals)+1)/2)
+	for key, val := range fields {
+		added[key] = val
+	}
+	for i := 0; i < len(keyvals); i += 2 {
+		key, ok := keyvals[i].(string)
+		if !ok {
+			key = fmt.Sprint(keyvals[i])
+		}
+		var val interface{}
+		if i+1 < len(keyvals) {
+			val = keyvals[i+1]
+		}
+		added[key] = val
+	}
+	return added
+}
+
+// Fields returns the structured context attached to the chain of nested
+// *Err, starting with the *Err this is called on.  Where several *Err in the
+// chain have a value for the same key, the value attached to the outermost
+// *Err is returned.  The map returned is a copy, and may be modified freely.
+func (err *Err) Fields() map[string]interface{} {
+	fields := make(map[string]interface{})
+	layers := err.layers()
+	for i := len(layers) - 1; i >= 0; i-- {
+		for key, val := range layers[i].fields {
+			fields[key] = val
+		}
+	}
+	return fields
+}
+
+// formatFields returns fields formatted as a single line of space separated
+// key=value pairs, sorted by key, followed by a newline; or an empty string
+// if there are no fields.  Values are quoted where necessary, so that the
+// line can be split unambiguously.
+func formatFields(fields map[string]interface{}) string {
+	if len(fields) == 0 {
+		return ""
+	}
+
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	buf := bytes.Buffer{}
+	for i, key := range keys {
+		if i > 0 {
+			buf.WriteByte(' ')
+		}
+		buf.WriteString(quoteField(key))
+		buf.WriteByte('=')
+		buf.WriteString(quoteField(fmt.Sprint(fields[key])))
+	}
+	buf.WriteByte('\n')
+	return buf.String()
+}
+
+// quoteField quotes s if it is empty, or contains spaces, quotes, equals
+// signs or control characters.
+func quoteField(s string) string {
+	if s == "" || strings.IndexFunc(s, func(r rune) bool {
+		return r <= ' ' || r == '=' || r == '"' || r == 0x7f
+	}) >= 0 {
+		return fmt.Sprintf("%q", s)
+	}
+	return s
+}
@@ -0,0 +1,15 @@
+package errors
+
+import (
+	"fmt"
+	"io"
+)
+
+func ExampleErr_With() {
+	// attach structured context to an error
+	err := New(io.ErrUnexpectedEOF).With("user_id", 42, "shard", 3)
+	// add to it from an outer layer
+	err = Wrapf(err, "loading profile", 0).With("shard", 4)
+	fmt.Println(err.Fields())
+	// Output: map[shard:4 user_id:42]
+}
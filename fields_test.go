@@ -0,0 +1,78 @@
+package errors
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// error strings used in this file
+const (
+	errFieldsIncorrect  = "the fields returned are not correct"
+	errFieldsNotInStack = "the fields are not included in the error stack"
+)
+
+// error format strings used in this file
+const (
+	withFailed   = ".With() failed; %v"
+	fieldsFailed = ".Fields() failed; %v"
+)
+
+func TestWith(t *testing.T) {
+	// test case: no fields
+	err := New(io.EOF)
+	if err.With() != err || len(err.Fields()) != 0 {
+		t.Errorf(withFailed, errFieldsIncorrect)
+	}
+
+	// test case: string, non-string and dangling keys, and replaced values
+	with := err.With("user_id", 42, "shard", 3)
+	withMore := with.With(7, "seven", "shard", 4, "dangling")
+	expected := map[string]interface{}{"user_id": 42, "shard": 4, "7": "seven", "dangling": nil}
+	if !reflect.DeepEqual(withMore.Fields(), expected) {
+		t.Errorf(withFailed, errFieldsIncorrect)
+	}
+
+	// test case: the *Err called on is left unmodified
+	if len(err.Fields()) != 0 || !reflect.DeepEqual(with.Fields(), map[string]interface{}{"user_id": 42, "shard": 3}) {
+		t.Errorf(withFailed, errFieldsIncorrect)
+	}
+	if withMore.Underlying != err.Underlying || !reflect.DeepEqual(withMore.Callers(), err.Callers()) {
+		t.Errorf(withFailed, errWrongUnderlyingError)
+	}
+}
+
+func TestFields(t *testing.T) {
+	// test case: outer layers override inner layers, including through %w
+	inner := New(io.EOF).With("user_id", 42, "shard", 3)
+	middle := Errorf("loading: %w", inner).With("shard", 4)
+	outer := New(middle).With("request", "abc")
+	expected := map[string]interface{}{"user_id": 42, "shard": 4, "request": "abc"}
+	if !reflect.DeepEqual(outer.Fields(), expected) {
+		t.Errorf(fieldsFailed, errFieldsIncorrect)
+	}
+
+	// test case: the map returned is a copy
+	outer.Fields()["request"] = "def"
+	if outer.Fields()["request"] != "abc" {
+		t.Errorf(fieldsFailed, errFieldsIncorrect)
+	}
+
+	// test case: fields are included in the error stack
+	if !strings.Contains(outer.ErrorStack(), "request=abc shard=4 user_id=42\n") {
+		t.Errorf(fieldsFailed, errFieldsNotInStack)
+	}
+	if !strings.Contains(outer.ParentErrorStack(), "\nrequest=abc\n") {
+		t.Errorf(fieldsFailed, errFieldsNotInStack)
+	}
+	if !strings.Contains(fmt.Sprintf("%+v", outer), "\nshard=3 user_id=42\n") {
+		t.Errorf(fieldsFailed, errFieldsNotInStack)
+	}
+
+	// test case: values are quoted where necessary
+	if formatFields(map[string]interface{}{"a b": "c=d", "e": "", "f": "g"}) != `"a b"="c=d" e="" f=g`+"\n" {
+		t.Errorf(fieldsFailed, errFieldsNotInStack)
+	}
+}
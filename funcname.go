@@ -0,0 +1,173 @@
+package errors
+
+import (
+	"strings"
+)
+
+// funcName is a fully qualified function name, as reported by the runtime or
+// printed in the stack of a panic, broken into its parts.
+type funcName struct {
+	// the package path, such as "example.com/a"
+	pkg string
+	// everything following the package path, such as "(*T).M.func1"
+	name string
+	// the receiver type of a method, such as "*T"
+	receiver string
+	// the function or method, such as "M"
+	function string
+	// the path of nested closures within function, such as "func1" or
+	// "func2.1"
+	closure string
+	// the type arguments of a generic function, or of the receiver type of a
+	// method of a generic type, as reported by the runtime, such as "..." or
+	// "example.com/b.T"
+	typeParams string
+}
+
+// parseFuncName breaks symbol, a fully qualified function name such as
+// "example.com/a.(*T).M.func1" or "example.com/a.F[example.com/b.T]", into
+// its parts.  Type arguments may themselves contain package paths, and so
+// are not searched for the separators of the enclosing name.
+func parseFuncName(symbol string) funcName {
+	var f funcName
+
+	// the package path is everything before the first "." following its
+	// last "/"; the package name contains no "/", whereas the rest may
+	start := 0
+	if slash := lastIndexOutside(symbol, '/'); slash >= 0 {
+		start = slash + 1
+	}
+	if period := indexOutside(symbol[start:], '.'); period >= 0 {
+		f.pkg = symbol[:start+period]
+		f.name = symbol[start+period+1:]
+	} else {
+		f.pkg = symbol[:start]
+		f.name = symbol[start:]
+	}
+	// old versions of go used center dots rather than periods
+	f.name = strings.Replace(f.name, "·", ".", -1)
+
+	parts := splitOutside(f.name, '.')
+	first, rest := parts[0], parts[1:]
+	switch {
+	case strings.HasPrefix(first, "(") && strings.HasSuffix(first, ")"):
+		// a pointer receiver, as in "(*T).M"
+		f.receiver = first[1 : len(first)-1]
+		if len(rest) > 0 {
+			f.function, rest = rest[0], rest[1:]
+		}
+	case first == "func" || isClosure(first):
+		// a closure at package level, as in go 1.4's "func·001"
+		rest = parts
+	case len(rest) > 0 && rest[0] != "" && !isClosure(rest[0]) && !(first == "init" && isDigits(rest[0])):
+		// a value receiver, as in "T.M"
+		f.receiver, f.function, rest = first, rest[0], rest[1:]
+	default:
+		f.function = first
+		// a package may have several init functions, numbered "init.0" on
+		if first == "init" && len(rest) > 0 && isDigits(rest[0]) {
+			f.function += "." + rest[0]
+			rest = rest[1:]
+		}
+	}
+
+	// closures of package level variables are numbered within "glob.", as
+	// in "glob..func1"
+	closures := make([]string, 0, len(rest))
+	for _, part := range rest {
+		if part != "" {
+			closures = append(closures, part)
+		}
+	}
+	f.closure = strings.Join(closures, ".")
+
+	if f.receiver != "" {
+		f.receiver, f.typeParams = cutTypeParams(f.receiver)
+	} else {
+		f.function, f.typeParams = cutTypeParams(f.function)
+	}
+	return f
+}
+
+// isClosure reports whether part is an element of the path of a closure, as
+// named by the compiler, such as "func1", "1", "gowrap1" or "deferwrap1".
+func isClosure(part string) bool {
+	for _, prefix := range []string{"func", "gowrap", "deferwrap"} {
+		if strings.HasPrefix(part, prefix) && isDigits(part[len(prefix):]) {
+			return true
+		}
+	}
+	return isDigits(part)
+}
+
+// isDigits reports whether s is made only of, and at least one, decimal
+// digits.
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// cutTypeParams splits name, such as "F[...]", into the name without its type
+// arguments and the type arguments without their brackets.
+func cutTypeParams(name string) (string, string) {
+	open := strings.IndexByte(name, '[')
+	if open < 0 || !strings.HasSuffix(name, "]") {
+		return name, ""
+	}
+	return name[:open], name[open+1 : len(name)-1]
+}
+
+// indexOutside returns the index of the first instance of c in s that is not
+// within brackets or parentheses, or -1 if there is none.
+func indexOutside(s string, c byte) int {
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '[', '(':
+			depth++
+		case ']', ')':
+			depth--
+		case c:
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// lastIndexOutside returns the index of the last instance of c in s that is
+// not within brackets or parentheses, or -1 if there is none.
+func lastIndexOutside(s string, c byte) int {
+	last := -1
+	for i := 0; i < len(s); {
+		next := indexOutside(s[i:], c)
+		if next < 0 {
+			break
+		}
+		last = i + next
+		i = last + 1
+	}
+	return last
+}
+
+// splitOutside splits s at each instance of sep that is not within brackets
+// or parentheses.
+func splitOutside(s string, sep byte) []string {
+	var parts []string
+	for {
+		i := indexOutside(s, sep)
+		if i < 0 {
+			return append(parts, s)
+		}
+		parts = append(parts, s[:i])
+		s = s[i+1:]
+	}
+}
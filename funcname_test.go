@@ -0,0 +1,35 @@
+package errors
+
+import (
+	"testing"
+)
+
+func TestParseFuncName(t *testing.T) {
+	cases := []struct {
+		symbol string
+		want   funcName
+	}{
+		{"main.main", funcName{pkg: "main", name: "main", function: "main"}},
+		{"net/http.(*Server).Serve", funcName{pkg: "net/http", name: "(*Server).Serve", receiver: "*Server", function: "Serve"}},
+		{"example.com/a.T.M", funcName{pkg: "example.com/a", name: "T.M", receiver: "T", function: "M"}},
+		{"example.com/a.F.func2.1", funcName{pkg: "example.com/a", name: "F.func2.1", function: "F", closure: "func2.1"}},
+		{"example.com/a.(*T).M.func1", funcName{pkg: "example.com/a", name: "(*T).M.func1", receiver: "*T", function: "M", closure: "func1"}},
+		{"example.com/a.glob..func1", funcName{pkg: "example.com/a", name: "glob..func1", function: "glob", closure: "func1"}},
+		{"example.com/a.init.0", funcName{pkg: "example.com/a", name: "init.0", function: "init.0"}},
+		{"example.com/a.init.func1", funcName{pkg: "example.com/a", name: "init.func1", function: "init", closure: "func1"}},
+		{"example.com/a.F.gowrap1", funcName{pkg: "example.com/a", name: "F.gowrap1", function: "F", closure: "gowrap1"}},
+		{"example.com/a.func·001", funcName{pkg: "example.com/a", name: "func.001", closure: "func.001"}},
+		{"example.com/a.F[...]", funcName{pkg: "example.com/a", name: "F[...]", function: "F", typeParams: "..."}},
+		{"example.com/a.F[example.com/b.T]", funcName{pkg: "example.com/a", name: "F[example.com/b.T]", function: "F", typeParams: "example.com/b.T"}},
+		{"example.com/a.F[example.com/b.T].func1", funcName{pkg: "example.com/a", name: "F[example.com/b.T].func1", function: "F", closure: "func1", typeParams: "example.com/b.T"}},
+		{"example.com/a.(*T[example.com/b.U]).M", funcName{pkg: "example.com/a", name: "(*T[example.com/b.U]).M", receiver: "*T", function: "M", typeParams: "example.com/b.U"}},
+		{"example.com/a.T[...].M", funcName{pkg: "example.com/a", name: "T[...].M", receiver: "T", function: "M", typeParams: "..."}},
+		{"example.com/a", funcName{pkg: "example.com/", name: "a", function: "a"}},
+	}
+
+	for _, c := range cases {
+		if got := parseFuncName(c.symbol); got != c.want {
+			t.Errorf("parseFuncName(%q) = %+v, rather than %+v", c.symbol, got, c.want)
+		}
+	}
+}
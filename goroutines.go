@@ -0,0 +1,161 @@
+package errors
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A Goroutine is the stack of a single goroutine, as printed by a go program
+// that panicked with GOTRACEBACK=all, or that received SIGQUIT.
+type Goroutine struct {
+	// The ID of the goroutine
+	ID int
+	// The State of the goroutine, such as "running", "chan receive" or
+	// "IO wait"
+	State string
+	// How long the goroutine had been blocked, to the minute, if it had
+	// been blocked for at least a minute
+	Wait time.Duration
+	// Whether the goroutine was Locked to its thread, by
+	// runtime.LockOSThread
+	Locked bool
+	// The Stack of the goroutine, starting with the innermost function call
+	Stack Stack
+	// The frame of the go statement that created the goroutine, or nil for
+	// the main goroutine and goroutines started by the runtime
+	Creator *StackFrame
+	// The ID of the goroutine that executed the go statement that created
+	// this one, if printed, as by go 1.21 on
+	CreatorID int
+}
+
+// A GoroutineGroup is a group of goroutines with identical stacks, such as a
+// pool of workers all blocked in the same place.  Goroutines are grouped if
+// they have the same State, Locked, Stack and Creator, regardless of their
+// IDs and of how long they have been blocked.
+type GoroutineGroup struct {
+	// The State shared by the goroutines
+	State string
+	// Whether the goroutines were Locked to their threads
+	Locked bool
+	// The Stack shared by the goroutines
+	Stack Stack
+	// The frame of the go statement that created the goroutines, if any
+	Creator *StackFrame
+	// The Goroutines, in the order in which they were printed
+	Goroutines []Goroutine
+}
+
+// ParseGoroutineDump parses the stack of every goroutine printed by a go
+// program, such as when it panics with GOTRACEBACK=all or receives SIGQUIT,
+// and returns them in the order in which they were printed.  Anything other
+// than the stacks of goroutines, such as a panic's message or the values of
+// registers, is ignored.  An error is returned if the stack of a goroutine
+// is malformed, or if there are none.
+func ParseGoroutineDump(text string) ([]Goroutine, error) {
+	goroutines, err := parseGoroutines(splitLines(text))
+	if err != nil {
+		return nil, err
+	}
+	if len(goroutines) == 0 {
+		return nil, Errorf("could not parse goroutine dump: no goroutines found")
+	}
+	return goroutines, nil
+}
+
+// parseGoroutines parses the stack of every goroutine printed on lines, as
+// described by ParseGoroutineDump, but without requiring that there be any.
+func parseGoroutines(lines []string) ([]Goroutine, error) {
+	var goroutines []Goroutine
+	for i := 0; i < len(lines); i++ {
+		id, status, ok := parseGoroutineHeader(lines[i])
+		if !ok {
+			continue
+		}
+
+		g := Goroutine{ID: id}
+		g.State, g.Wait, g.Locked = parseGoroutineStatus(status)
+
+		stack, creator, creatorID, next, err := parseGoroutineStack(lines, i+1)
+		if err != nil {
+			return nil, err
+		}
+		g.Stack, g.Creator, g.CreatorID = stack, creator, creatorID
+
+		goroutines = append(goroutines, g)
+		i = next - 1
+	}
+	return goroutines, nil
+}
+
+// GroupGoroutines groups goroutines with identical stacks, as described by
+// GoroutineGroup.  Groups are returned in the order in which their first
+// goroutine appears in goroutines.
+func GroupGoroutines(goroutines []Goroutine) []GoroutineGroup {
+	var groups []GoroutineGroup
+	// the indices of the groups with each hash of their stack
+	byHash := make(map[uint64][]int)
+	for _, g := range goroutines {
+		hash := g.Stack.Hash()
+
+		found := false
+		for _, i := range byHash[hash] {
+			if groups[i].matches(&g) {
+				groups[i].Goroutines = append(groups[i].Goroutines, g)
+				found = true
+				break
+			}
+		}
+		if found {
+			continue
+		}
+
+		byHash[hash] = append(byHash[hash], len(groups))
+		groups = append(groups, GoroutineGroup{
+			State:      g.State,
+			Locked:     g.Locked,
+			Stack:      g.Stack,
+			Creator:    g.Creator,
+			Goroutines: []Goroutine{g},
+		})
+	}
+	return groups
+}
+
+// matches reports whether g belongs in the group.
+func (group *GoroutineGroup) matches(g *Goroutine) bool {
+	if group.State != g.State || group.Locked != g.Locked || len(group.Stack) != len(g.Stack) {
+		return false
+	}
+	if (group.Creator == nil) != (g.Creator == nil) || group.Creator != nil && *group.Creator != *g.Creator {
+		return false
+	}
+	for i := range group.Stack {
+		if group.Stack[i] != g.Stack[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// parseGoroutineStatus parses the status of a goroutine, as printed within
+// the brackets of the line introducing its stack, such as "chan receive, 5
+// minutes, locked to thread", into its state, how long it had been blocked,
+// and whether it was locked to its thread.
+func parseGoroutineStatus(status string) (state string, wait time.Duration, locked bool) {
+	parts := strings.Split(status, ", ")
+	state = parts[0]
+	for _, part := range parts[1:] {
+		switch {
+		case part == "locked to thread":
+			locked = true
+		case strings.HasSuffix(part, " minutes") || strings.HasSuffix(part, " minute"):
+			n, err := strconv.Atoi(part[:strings.IndexByte(part, ' ')])
+			if err == nil {
+				wait = time.Duration(n) * time.Minute
+			}
+		}
+	}
+	return state, wait, locked
+}
@@ -0,0 +1,35 @@
+package errors
+
+import (
+	"fmt"
+)
+
+func ExampleGroupGoroutines() {
+	dump := `goroutine 1 [running]:
+main.main()
+	/src/main.go:20 +0x25
+
+goroutine 6 [chan receive, 5 minutes]:
+main.worker(0xc00001e0c0)
+	/src/main.go:9 +0x3c
+created by main.main in goroutine 1
+	/src/main.go:16 +0x45
+
+goroutine 7 [chan receive, 5 minutes]:
+main.worker(0xc00001e0c0)
+	/src/main.go:9 +0x3c
+created by main.main in goroutine 1
+	/src/main.go:16 +0x45
+`
+
+	goroutines, err := ParseGoroutineDump(dump)
+	if err != nil {
+		panic(err)
+	}
+	for _, group := range GroupGoroutines(goroutines) {
+		fmt.Printf("%d goroutines [%s] in %s\n", len(group.Goroutines), group.State, group.Stack[0].Name)
+	}
+	// Output:
+	// 1 goroutines [running] in main
+	// 2 goroutines [chan receive] in worker
+}
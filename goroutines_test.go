@@ -0,0 +1,124 @@
+package errors
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// readDump returns the contents of the goroutine dump in testdata/goroutines
+// with the given name.
+func readDump(t *testing.T, name string) string {
+	text, err := ioutil.ReadFile(filepath.Join("testdata", "goroutines", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(text)
+}
+
+func TestParseGoroutineDump(t *testing.T) {
+	goroutines, err := ParseGoroutineDump(readDump(t, "go1.20-gotraceback-all.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Goroutine{
+		{ID: 1, State: "running"},
+		{ID: 18, State: "IO wait", Wait: 12 * time.Minute, Locked: true},
+		{ID: 19, State: "chan receive", Wait: 5 * time.Minute},
+		{ID: 20, State: "chan receive", Wait: time.Minute},
+		{ID: 21, State: "chan receive"},
+	}
+	if len(goroutines) != len(want) {
+		t.Fatalf("%d goroutines were parsed, rather than %d", len(goroutines), len(want))
+	}
+	for i, g := range goroutines {
+		if g.ID != want[i].ID || g.State != want[i].State || g.Wait != want[i].Wait || g.Locked != want[i].Locked {
+			t.Errorf("goroutine %d was parsed as %+v", i, g)
+		}
+	}
+
+	if main := goroutines[0]; len(main.Stack) != 1 || main.Creator != nil {
+		t.Errorf("the running goroutine was parsed as %+v", main)
+	}
+	serve := goroutines[1]
+	if len(serve.Stack) != 8 || serve.Stack[7].Name != "serve" || serve.Stack[2].Name != "(*pollDesc).waitRead" {
+		t.Errorf("the stack of goroutine 18 was parsed as:\n%s", serve.Stack)
+	}
+	if serve.Creator == nil || serve.Creator.Name != "main" || serve.Creator.LineNumber != 35 || serve.CreatorID != 0 {
+		t.Errorf("the creator of goroutine 18 was parsed as %+v", serve.Creator)
+	}
+}
+
+func TestParseGoroutineDumpSIGQUIT(t *testing.T) {
+	goroutines, err := ParseGoroutineDump(readDump(t, "go1.27-sigquit.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(goroutines) != 10 || goroutines[0].ID != 0 || goroutines[0].State != "idle" {
+		t.Fatalf("%d goroutines were parsed, starting with %+v", len(goroutines), goroutines[0])
+	}
+	locked := goroutines[8]
+	if locked.ID != 8 || !locked.Locked || locked.State != "chan receive" {
+		t.Errorf("the goroutine locked to its thread was parsed as %+v", locked)
+	}
+	if locked.Creator == nil || locked.Creator.Name != "main" || locked.CreatorID != 1 {
+		t.Errorf("the creator of the goroutine locked to its thread was parsed as %+v", locked.Creator)
+	}
+	if !locked.Stack.Contains("main.locked") || locked.Stack[len(locked.Stack)-1].Name != "goexit" {
+		t.Errorf("the stack of the goroutine locked to its thread was parsed as:\n%s", locked.Stack)
+	}
+}
+
+func TestGroupGoroutines(t *testing.T) {
+	cases := map[string][][]int{
+		// the workers are grouped whatever they have been blocked for
+		"go1.20-gotraceback-all.txt": {{1}, {18}, {19, 20, 21}},
+		// the workers are grouped whatever the arguments of their calls,
+		// but not with the goroutine locked to its thread
+		"go1.27-sigquit.txt": {{0}, {1}, {2}, {3}, {4}, {5, 6, 7}, {8}, {9}},
+	}
+
+	for name, want := range cases {
+		goroutines, err := ParseGoroutineDump(readDump(t, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		groups := GroupGoroutines(goroutines)
+		if len(groups) != len(want) {
+			t.Errorf("%s: %d groups were made, rather than %d", name, len(groups), len(want))
+			continue
+		}
+		for i, group := range groups {
+			ids := make([]int, len(group.Goroutines))
+			for j, g := range group.Goroutines {
+				ids[j] = g.ID
+			}
+			if len(ids) != len(want[i]) {
+				t.Errorf("%s: group %d holds goroutines %v, rather than %v", name, i, ids, want[i])
+				continue
+			}
+			for j := range ids {
+				if ids[j] != want[i][j] {
+					t.Errorf("%s: group %d holds goroutines %v, rather than %v", name, i, ids, want[i])
+					break
+				}
+			}
+		}
+	}
+}
+
+func TestParseGoroutineDumpInvalid(t *testing.T) {
+	for name, text := range map[string]string{
+		"notPanic":          notPanic,
+		"invalidCreatedBy":  invalidCreatedBy,
+		"invalidCreatedBy3": invalidCreatedByThree,
+	} {
+		if _, err := ParseGoroutineDump(text); err == nil {
+			t.Errorf("%s was parsed without error", name)
+		}
+	}
+}
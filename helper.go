@@ -0,0 +1,89 @@
+package errors
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// helpers holds the fully qualified names of the functions, and the paths
+// of the packages, registered as helpers.
+var helpers = struct {
+	sync.RWMutex
+	names map[string]struct{}
+}{names: make(map[string]struct{})}
+
+// helpersRegistered is set to 1 once any helper is registered, so that
+// stacks need not be searched for helpers until then.
+var helpersRegistered int32
+
+// Helper marks the calling function as a helper, in the same way as
+// testing.T.Helper does.  Frames of helpers at the top of a stack are
+// skipped when the stack is captured by New, Wrap, Wrapf and Errorf, so that
+// stacks start at the function that called the helper, and Callers,
+// StackTrace and StackFrames all agree.  Helper must therefore be called
+// before the helper makes its error; stacks captured before then are not
+// changed.  Helper may be called from several goroutines at once, and need
+// only be called once, although calling it on every call of the helper is
+// cheap.
+func Helper() {
+	var pc [1]uintptr
+	if runtime.Callers(2, pc[:]) == 0 {
+		return
+	}
+	fn := runtime.FuncForPC(pc[0] - 1)
+	if fn == nil {
+		return
+	}
+
+	name := fn.Name()
+	helpers.RLock()
+	_, ok := helpers.names[name]
+	helpers.RUnlock()
+	if !ok {
+		RegisterHelper(name)
+	}
+}
+
+// RegisterHelper marks the given functions and packages as helpers, as
+// described by Helper.  Functions are named by their fully qualified name,
+// such as "example.com/a.(*T).M", and packages by their path, such as
+// "example.com/a", in which case every function in the package is a helper.
+func RegisterHelper(names ...string) {
+	helpers.Lock()
+	for _, name := range names {
+		helpers.names[name] = struct{}{}
+	}
+	helpers.Unlock()
+	atomic.StoreInt32(&helpersRegistered, 1)
+}
+
+// isHelper reports whether the function with the given fully qualified name
+// is a helper.
+func isHelper(symbol string) bool {
+	if atomic.LoadInt32(&helpersRegistered) == 0 {
+		return false
+	}
+
+	helpers.RLock()
+	defer helpers.RUnlock()
+	if _, ok := helpers.names[symbol]; ok {
+		return true
+	}
+	_, ok := helpers.names[parseFuncName(symbol).pkg]
+	return ok
+}
+
+// skipHelperCallers returns the number of program counters at the start of
+// stack, as returned by runtime.Callers, that are in helpers.
+func skipHelperCallers(stack []uintptr) int {
+	for i, pc := range stack {
+		// runtime.Callers expands inlined calls into program counters of
+		// their own, so each is looked up as a call, as by StackFrame.Func
+		fn := runtime.FuncForPC(pc - 1)
+		if fn == nil || !isHelper(fn.Name()) {
+			return i
+		}
+	}
+	return len(stack)
+}
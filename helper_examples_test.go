@@ -0,0 +1,21 @@
+package errors
+
+import (
+	"fmt"
+)
+
+// notFound is a helper that makes the errors returned by its callers.
+//
+//go:noinline
+func notFound(name string) error {
+	Helper()
+	return Errorf("%s not found", name)
+}
+
+func ExampleHelper() {
+	err := notFound("config.yaml")
+
+	// the stack starts at the caller of notFound
+	fmt.Println(err.(*Err).StackFrames()[0].Name)
+	// Output: ExampleHelper
+}
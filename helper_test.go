@@ -0,0 +1,101 @@
+package errors
+
+import (
+	"reflect"
+	"sync/atomic"
+	"testing"
+)
+
+// error strings used in tests
+// declared globally to allow reuse for consistency
+const (
+	errHelperNotSkipped = "the stack starts in a helper (%s), rather than in its caller"
+)
+
+// the following are not inlined, so that they would appear in the stacks
+// they capture if they were not helpers
+
+//go:noinline
+func helperNew() *Err {
+	Helper()
+	return New(testMsgFoo)
+}
+
+//go:noinline
+func helperWrap() *Err {
+	Helper()
+	return helperNew()
+}
+
+//go:noinline
+func registeredHelperNew() *Err {
+	return New(testMsgFoo)
+}
+
+// resetHelpers forgets every helper, so that tests registering helpers do
+// not affect those that follow them.
+func resetHelpers() {
+	helpers.Lock()
+	helpers.names = make(map[string]struct{})
+	helpers.Unlock()
+	atomic.StoreInt32(&helpersRegistered, 0)
+}
+
+func TestHelper(t *testing.T) {
+	t.Cleanup(resetHelpers)
+
+	for _, err := range []*Err{helperNew(), helperWrap()} {
+		if frame := err.StackFrames()[0]; frame.Name != "TestHelper" {
+			t.Errorf(errHelperNotSkipped, frame.Name)
+		}
+		// helpers are skipped as the stack is captured
+		if frame := NewStackFrame(err.Callers()[0]); frame.Name != "TestHelper" {
+			t.Errorf(errHelperNotSkipped, frame.Name)
+		}
+	}
+}
+
+func TestRegisterHelper(t *testing.T) {
+	t.Cleanup(resetHelpers)
+	pkg := reflect.TypeOf(Err{}).PkgPath()
+
+	// stacks captured before a helper is registered are left as they were,
+	// so that their frames and program counters agree
+	before := registeredHelperNew()
+	RegisterHelper(pkg + ".registeredHelperNew")
+	after := registeredHelperNew()
+
+	if frame := before.StackFrames()[0]; frame.Name != "registeredHelperNew" {
+		t.Errorf("a stack captured before its helper was registered starts in %s", frame.Name)
+	}
+	if frame := after.StackFrames()[0]; frame.Name != "TestRegisterHelper" {
+		t.Errorf(errHelperNotSkipped, frame.Name)
+	}
+	for _, err := range []*Err{before, after} {
+		if len(err.StackFrames()) != len(err.Callers()) || err.StackFrames()[0].Name != NewStackFrame(err.Callers()[0]).Name {
+			t.Errorf("the frames of the stack do not agree with its program counters")
+		}
+	}
+
+	RegisterHelper("example.com/helpers")
+	if !isHelper("example.com/helpers.(*T).Wrap") || isHelper("example.com/helpers/sub.Wrap") {
+		t.Errorf("functions were not matched to the helper packages they are in")
+	}
+}
+
+func TestResetHelpers(t *testing.T) {
+	RegisterHelper("example.com/helpers")
+	resetHelpers()
+	if isHelper("example.com/helpers.Wrap") || atomic.LoadInt32(&helpersRegistered) != 0 {
+		t.Errorf("helpers were not forgotten")
+	}
+}
+
+func TestSkipNegative(t *testing.T) {
+	// stacks never start within this package
+	for _, err := range []*Err{New(testMsgFoo, Skip(-2)), Wrap(testMsgFoo, -2)} {
+		if frame := err.StackFrames()[0]; frame.Name != "TestSkipNegative" {
+			t.Errorf("a negative skip started the stack in %s", frame.Name)
+		}
+	}
+}
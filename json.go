@@ -0,0 +1,285 @@
+package errors
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+// errType is the type name reported by TypeName for an *Err whose underlying
+// error is itself an *Err.
+var errType = reflect.TypeOf(&Err{}).String()
+
+// jsonErr is the JSON representation of a single *Err in a chain of nested
+// *Err.
+type jsonErr struct {
+	// the error message, as returned by Error
+	Message string `json:"message"`
+	// the prefix added by Wrapf, if any
+	Prefix string `json:"prefix,omitempty"`
+	// the type name, as returned by TypeName
+	Type string `json:"type"`
+	// the fields attached to this *Err only
+	Fields map[string]interface{} `json:"fields,omitempty"`
+	// whether ignoreNestedStack is set
+	IgnoreNestedStack bool `json:"ignoreNestedStack,omitempty"`
+	// the stack of this *Err only
+	Stack []jsonFrame `json:"stack"`
+	// the stack of this *Err only, collapsed as by Stack.Collapsed, if that
+	// makes it shorter
+	Collapsed []jsonFrame `json:"collapsed,omitempty"`
+	// the details of the panic or fatal error, if the underlying error is a
+	// *Panic or a *FatalError
+	Panic *jsonPanic `json:"panic,omitempty"`
+	// the next nested *Err, as returned by AssertUnderlying, if any
+	Cause *jsonErr `json:"cause,omitempty"`
+}
+
+// jsonFrame is the JSON representation of a StackFrame.
+type jsonFrame struct {
+	File       string  `json:"file"`
+	Line       int     `json:"line"`
+	Package    string  `json:"package"`
+	Name       string  `json:"name"`
+	Receiver   string  `json:"receiver,omitempty"`
+	Function   string  `json:"function,omitempty"`
+	Closure    string  `json:"closure,omitempty"`
+	TypeParams string  `json:"typeParams,omitempty"`
+	PC         uintptr `json:"pc"`
+	Inlined    bool    `json:"inlined,omitempty"`
+	Elided     int     `json:"elided,omitempty"`
+	Repeated   int     `json:"repeated,omitempty"`
+	Cycle      int     `json:"cycle,omitempty"`
+}
+
+// jsonPanic is the JSON representation of a *Panic, or of a *FatalError
+// without its goroutines.
+type jsonPanic struct {
+	Message  string      `json:"message"`
+	Kind     string      `json:"kind,omitempty"`
+	Index    int         `json:"index,omitempty"`
+	Length   int         `json:"length,omitempty"`
+	Details  []string    `json:"details,omitempty"`
+	Signal   *jsonSignal `json:"signal,omitempty"`
+	Previous *jsonPanic  `json:"previous,omitempty"`
+}
+
+// jsonSignal is the JSON representation of a Signal.
+type jsonSignal struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Code        uint64 `json:"code"`
+	Addr        uint64 `json:"addr"`
+	PC          uint64 `json:"pc"`
+}
+
+// decodedErr is the underlying error of an *Err decoded from JSON, where
+// that error was not itself an *Err.  It reports the type name and message of
+// the original error, and wraps the next nested *Err, if there was one.
+type decodedErr struct {
+	typeName string
+	message  string
+	cause    *Err
+}
+
+func (e *decodedErr) Error() string {
+	return e.message
+}
+
+func (e *decodedErr) Unwrap() error {
+	if e.cause == nil {
+		return nil
+	}
+	return e.cause
+}
+
+// MarshalJSON implements json.Marshaler.  The JSON produced describes the
+// chain of nested *Err, starting with the *Err this is called on, with each
+// layer's message, prefix, type name, fields and stack frames, and its
+// nested *Err as its cause.  Each layer's stack frames are those of
+// StackFrames; where some repeat, they are also given collapsed, as by
+// Stack.Collapsed, as the layer's "collapsed" frames.  The details of a
+// *Panic or *FatalError parsed by ParsePanic, such as its Kind and Signal,
+// are included, though the goroutines of a *FatalError are not.
+func (err *Err) MarshalJSON() ([]byte, error) {
+	var j *jsonErr
+	layers := err.layers()
+	for i := len(layers) - 1; i >= 0; i-- {
+		j = newJSONErr(layers[i], j)
+	}
+	return json.Marshal(j)
+}
+
+// UnmarshalJSON implements json.Unmarshaler.  It decodes JSON as produced by
+// MarshalJSON into the *Err this is called on, such that ErrorStack,
+// StackFrames, Cause and the like behave as they did for the original *Err.
+// Underlying errors that were not of type *Err are decoded as errors with the
+// same message and type name, except for a *Panic, which is decoded whole,
+// and a *FatalError, which is decoded without its goroutines.  Stacks are
+// decoded as StackFrames only, as program counters are meaningless outside
+// the originating process, from the frames as they were rather than those
+// collapsed.  As is conventional, decoding JSON null is a no-op.
+func (err *Err) UnmarshalJSON(data []byte) error {
+	if string(bytes.TrimSpace(data)) == "null" {
+		return nil
+	}
+	var j jsonErr
+	if e := json.Unmarshal(data, &j); e != nil {
+		return e
+	}
+	*err = *j.err()
+	return nil
+}
+
+// newJSONErr returns the JSON representation of e, with cause as its nested
+// *Err.
+func newJSONErr(e *Err, cause *jsonErr) *jsonErr {
+	frames := e.ParentStackFrames()
+	j := &jsonErr{
+		Message:           e.Error(),
+		Prefix:            e.prefix,
+		Type:              e.TypeName(),
+		Fields:            e.fields,
+		IgnoreNestedStack: e.ignoreNestedStack,
+		Stack:             newJSONFrames(frames),
+		Cause:             cause,
+	}
+	if collapsed := frames.Collapsed(); len(collapsed) < len(frames) {
+		j.Collapsed = newJSONFrames(collapsed)
+	}
+
+	switch u := e.Underlying.(type) {
+	case *Panic:
+		j.Panic = newJSONPanic(u)
+	case *FatalError:
+		j.Panic = &jsonPanic{Message: u.Message, Details: u.Details, Signal: newJSONSignal(u.Signal)}
+	}
+	return j
+}
+
+// newJSONFrames returns the JSON representation of frames.
+func newJSONFrames(frames Stack) []jsonFrame {
+	j := make([]jsonFrame, len(frames))
+	for i, frame := range frames {
+		j[i] = jsonFrame{
+			File:       frame.File,
+			Line:       frame.LineNumber,
+			Package:    frame.Package,
+			Name:       frame.Name,
+			Receiver:   frame.Receiver,
+			Function:   frame.Function,
+			Closure:    frame.Closure,
+			TypeParams: frame.TypeParams,
+			PC:         frame.ProgramCounter,
+			Inlined:    frame.Inlined,
+			Elided:     frame.Elided,
+			Repeated:   frame.Repeated,
+			Cycle:      frame.Cycle,
+		}
+	}
+	return j
+}
+
+// newJSONPanic returns the JSON representation of p, and of the panics
+// before it.
+func newJSONPanic(p *Panic) *jsonPanic {
+	if p == nil {
+		return nil
+	}
+	return &jsonPanic{
+		Message:  p.Message,
+		Kind:     p.Kind.String(),
+		Index:    p.Index,
+		Length:   p.Length,
+		Signal:   newJSONSignal(p.Signal),
+		Previous: newJSONPanic(p.Previous),
+	}
+}
+
+// newJSONSignal returns the JSON representation of s.
+func newJSONSignal(s *Signal) *jsonSignal {
+	if s == nil {
+		return nil
+	}
+	return &jsonSignal{Name: s.Name, Description: s.Description, Code: s.Code, Addr: s.Addr, PC: s.PC}
+}
+
+// err returns the *Err described by j.
+func (j *jsonErr) err() *Err {
+	frames := make([]StackFrame, len(j.Stack))
+	for i, frame := range j.Stack {
+		frames[i] = StackFrame{
+			File:           frame.File,
+			LineNumber:     frame.Line,
+			Package:        frame.Package,
+			Name:           frame.Name,
+			Receiver:       frame.Receiver,
+			Function:       frame.Function,
+			Closure:        frame.Closure,
+			TypeParams:     frame.TypeParams,
+			ProgramCounter: frame.PC,
+			Inlined:        frame.Inlined,
+			Elided:         frame.Elided,
+			Repeated:       frame.Repeated,
+			Cycle:          frame.Cycle,
+		}
+	}
+	e := &Err{
+		prefix:            j.Prefix,
+		fields:            j.Fields,
+		ignoreNestedStack: j.IgnoreNestedStack,
+		frames:            resolvedFrameCache(frames),
+	}
+
+	var cause *Err
+	if j.Cause != nil {
+		cause = j.Cause.err()
+	}
+	message := j.Message
+	if j.Prefix != "" {
+		message = strings.TrimPrefix(message, j.Prefix+": ")
+	}
+
+	switch {
+	case cause != nil && j.Type == errType && message == cause.Error():
+		e.Underlying = cause
+	case cause == nil && j.Type == nilType:
+		e.Underlying = nil
+	case cause == nil && j.Type == "panic" && j.Panic != nil:
+		e.Underlying = j.Panic.panic()
+	case cause == nil && j.Type == "fatal error" && j.Panic != nil:
+		e.Underlying = &FatalError{Message: j.Panic.Message, Details: j.Panic.Details, Signal: j.Panic.Signal.signal()}
+	default:
+		e.Underlying = &decodedErr{typeName: j.Type, message: message, cause: cause}
+	}
+	return e
+}
+
+// panic returns the *Panic described by j.
+func (j *jsonPanic) panic() *Panic {
+	if j == nil {
+		return nil
+	}
+	p := &Panic{
+		Message:  j.Message,
+		Index:    j.Index,
+		Length:   j.Length,
+		Signal:   j.Signal.signal(),
+		Previous: j.Previous.panic(),
+	}
+	for kind := OtherPanic; kind <= TypeAssertion; kind++ {
+		if kind.String() == j.Kind {
+			p.Kind = kind
+		}
+	}
+	return p
+}
+
+// signal returns the Signal described by j.
+func (j *jsonSignal) signal() *Signal {
+	if j == nil {
+		return nil
+	}
+	return &Signal{Name: j.Name, Description: j.Description, Code: j.Code, Addr: j.Addr, PC: j.PC}
+}
@@ -0,0 +1,19 @@
+package errors
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+func ExampleErr_MarshalJSON() {
+	// example *Err returning function
+	err := func() *Err { return Wrapf(io.EOF, "reading header", 0) }()
+	// encode the error, along with its stack, for a log pipeline
+	data, _ := json.Marshal(err)
+	// decode it again elsewhere
+	decoded := &Err{}
+	_ = json.Unmarshal(data, decoded)
+	fmt.Println(decoded.TypeName(), decoded.Error())
+	// Output: *errors.errorString reading header: EOF
+}
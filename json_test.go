@@ -0,0 +1,183 @@
+package errors
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// error strings used in this file
+const (
+	errDecodedNotMatch = "the decoded error does not match the original"
+	errSchemaIncorrect = "the JSON produced does not follow the schema"
+)
+
+// error format strings used in this file
+const (
+	marshalJSONFailed   = ".MarshalJSON() failed; %v"
+	unmarshalJSONFailed = ".UnmarshalJSON() failed; %v"
+)
+
+// roundTrip encodes e as JSON and decodes it again
+func roundTrip(t *testing.T, e *Err) ([]byte, *Err) {
+	data, err := json.Marshal(e)
+	if err != nil {
+		t.Fatalf(marshalJSONFailed, err)
+	}
+	decoded := &Err{}
+	if err := json.Unmarshal(data, decoded); err != nil {
+		t.Fatalf(unmarshalJSONFailed, err)
+	}
+	return data, decoded
+}
+
+func TestMarshalJSON(t *testing.T) {
+	inner := New(io.EOF).With("user_id", "abc")
+	outer := Wrapf(Errorf("loading: %w", inner), testPrefixFoobar, 0).With("shard", "3")
+	data, _ := roundTrip(t, outer)
+
+	var j map[string]interface{}
+	if err := json.Unmarshal(data, &j); err != nil {
+		t.Fatalf(marshalJSONFailed, err)
+	}
+	if j["message"] != outer.Error() || j["prefix"] != testPrefixFoobar || j["type"] != "*errors.Err" {
+		t.Errorf(marshalJSONFailed, errSchemaIncorrect)
+	}
+	if !reflect.DeepEqual(j["fields"], map[string]interface{}{"shard": "3"}) {
+		t.Errorf(marshalJSONFailed, errSchemaIncorrect)
+	}
+	frame := j["stack"].([]interface{})[0].(map[string]interface{})
+	expected := outer.ParentStackFrames()[0]
+	if frame["file"] != expected.File || frame["line"] != float64(expected.LineNumber) ||
+		frame["package"] != expected.Package || frame["name"] != expected.Name ||
+		frame["pc"] != float64(expected.ProgramCounter) {
+		t.Errorf(marshalJSONFailed, errSchemaIncorrect)
+	}
+	cause := j["cause"].(map[string]interface{})
+	if cause["message"] != "loading: EOF" || cause["type"] != "*fmt.wrapError" {
+		t.Errorf(marshalJSONFailed, errSchemaIncorrect)
+	}
+	cause = cause["cause"].(map[string]interface{})
+	if cause["message"] != "EOF" || cause["type"] != "*errors.errorString" || cause["cause"] != nil {
+		t.Errorf(marshalJSONFailed, errSchemaIncorrect)
+	}
+	if j["collapsed"] != nil {
+		t.Errorf(marshalJSONFailed, "a stack without repeated frames was collapsed")
+	}
+}
+
+func TestMarshalJSONCollapsed(t *testing.T) {
+	recursed, err := ParsePanic(recursive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := roundTrip(t, recursed)
+
+	var j struct {
+		Stack     []jsonFrame `json:"stack"`
+		Collapsed []jsonFrame `json:"collapsed"`
+	}
+	if err := json.Unmarshal(data, &j); err != nil {
+		t.Fatalf(marshalJSONFailed, err)
+	}
+	// the frames as parsed, and those collapsed
+	if len(j.Stack) != 5 || len(j.Collapsed) != 4 || j.Collapsed[2].Repeated != 3 || j.Collapsed[2].Cycle != 1 {
+		t.Errorf(marshalJSONFailed, "the recursive frames were not collapsed: "+string(data))
+	}
+}
+
+func TestUnmarshalJSON(t *testing.T) {
+	cases := map[string]*Err{
+		"plain":    New(io.EOF),
+		"nil":      New(nil),
+		"nested":   Wrapf(New(New(io.EOF).With("user_id", "abc")), testPrefixFoobar, 0),
+		"wrapped":  Errorf("loading: %w", New(io.EOF)),
+		"ignoring": New(New(io.EOF)).SetIgnoreNestedStack(true),
+		"panic":    func() *Err { e, _ := ParsePanic(createdBy); return e }(),
+		"repeated": func() *Err { e, _ := ParsePanic(recursive); return e }(),
+		"elided":   recurse(2 * MaxStackDepth),
+	}
+
+	for name, original := range cases {
+		data, decoded := roundTrip(t, original)
+
+		if decoded.Error() != original.Error() || decoded.TypeName() != original.TypeName() {
+			t.Errorf(unmarshalJSONFailed, name+": "+errDecodedNotMatch)
+		}
+		if decoded.ErrorStack() != original.ErrorStack() || fmt.Sprintf("%+v", decoded) != fmt.Sprintf("%+v", original) {
+			t.Errorf(unmarshalJSONFailed, name+": "+errDecodedNotMatch)
+		}
+		if !reflect.DeepEqual(decoded.StackFrames(), original.StackFrames()) {
+			t.Errorf(unmarshalJSONFailed, name+": "+errDecodedNotMatch)
+		}
+		if !reflect.DeepEqual(decoded.Fields(), original.Fields()) {
+			t.Errorf(unmarshalJSONFailed, name+": "+errDecodedNotMatch)
+		}
+		if len(decoded.layers()) != len(original.layers()) {
+			t.Errorf(unmarshalJSONFailed, name+": "+errDecodedNotMatch)
+		}
+		if root, originalRoot := decoded.RootCause(), original.RootCause(); (root == nil) != (originalRoot == nil) ||
+			root != nil && root.Error() != originalRoot.Error() {
+			t.Errorf(unmarshalJSONFailed, name+": "+errDecodedNotMatch)
+		}
+
+		// the decoded error encodes to the same JSON as the original
+		if redata, _ := roundTrip(t, decoded); !bytes.Equal(redata, data) {
+			t.Errorf(unmarshalJSONFailed, name+": "+errDecodedNotMatch)
+		}
+	}
+
+	// test case: invalid JSON
+	if err := json.Unmarshal([]byte(`{"message": 42}`), &Err{}); err == nil {
+		t.Errorf(unmarshalJSONFailed, errErrorNotAppropriate)
+	}
+}
+
+func TestUnmarshalJSONNull(t *testing.T) {
+	original := New(io.EOF)
+	e := original.clone()
+	if err := e.UnmarshalJSON([]byte(" null ")); err != nil {
+		t.Fatalf(unmarshalJSONFailed, err)
+	}
+	if e.Underlying != io.EOF || e.TypeName() != original.TypeName() {
+		t.Errorf(unmarshalJSONFailed, "decoding null modified the *Err")
+	}
+
+	var holder struct{ Err *Err }
+	if err := json.Unmarshal([]byte(`{"Err": null}`), &holder); err != nil || holder.Err != nil {
+		t.Errorf(unmarshalJSONFailed, "decoding null did not leave a nil *Err")
+	}
+}
+
+func TestUnmarshalJSONPanic(t *testing.T) {
+	original, err := ParsePanic(nestedSignal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, decoded := roundTrip(t, original)
+	p, ok := As[*Panic](decoded)
+	if !ok || !reflect.DeepEqual(p, original.Underlying) {
+		t.Errorf(unmarshalJSONFailed, "panic: "+errDecodedNotMatch)
+	}
+
+	text, err := ioutil.ReadFile(filepath.Join("testdata", "fatal", "go1.27-out-of-memory.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if original, err = ParsePanic(string(text)); err != nil {
+		t.Fatal(err)
+	}
+	_, decoded = roundTrip(t, original)
+	fatal, ok := As[*FatalError](decoded)
+	want := *original.Underlying.(*FatalError)
+	// goroutines are not encoded
+	want.Goroutines = nil
+	if !ok || !reflect.DeepEqual(*fatal, want) {
+		t.Errorf(unmarshalJSONFailed, "fatal error: "+errDecodedNotMatch)
+	}
+}
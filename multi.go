@@ -0,0 +1,146 @@
+package errors
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// MultiErr is an error that aggregates several errors, such as the failures
+// of several operations that were run together.  Each error may be an *Err
+// with its own stack.
+type MultiErr struct {
+	// the aggregated errors; Join adds none that are nil, and any that are
+	// are reported as "<nil>"
+	Errs []error
+}
+
+// Join returns an error that aggregates the given errors, ignoring any that
+// are nil.  If every error is nil, nil is returned; otherwise the error
+// returned is a *MultiErr.
+func Join(errs ...error) error {
+	m := &MultiErr{}
+	for _, err := range errs {
+		if err != nil {
+			m.Errs = append(m.Errs, err)
+		}
+	}
+	if len(m.Errs) == 0 {
+		return nil
+	}
+	return m
+}
+
+// Error returns the error messages of the aggregated errors, separated by
+// newlines.
+func (m *MultiErr) Error() string {
+	msgs := make([]string, len(m.Errs))
+	for i, err := range m.Errs {
+		if err == nil {
+			msgs[i] = nilType
+			continue
+		}
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// Unwrap returns the aggregated errors, so that Is, As and Find, as well as
+// the standard library's errors.Is and errors.As, consider each of them.
+func (m *MultiErr) Unwrap() []error {
+	return m.Errs
+}
+
+// ErrorStack returns a string that contains the error message and callstack
+// of each aggregated error, as returned by its ErrorStack method.  The
+// callstack of an *Err that is identical to that of an earlier *Err is not
+// repeated.  The callstack of an *Err wrapped by an aggregated error that is
+// not itself an *Err, such as by fmt.Errorf's %w, is included too.
+func (m *MultiErr) ErrorStack() string {
+	return m.render(
+		func(e *Err) string { return e.ErrorStack() },
+		func(e *Err) string { return string(e.Stack()) },
+	)
+}
+
+// Format implements fmt.Formatter.  The %s and %v verbs print the error
+// message, and %q prints it quoted.  The %+v verb prints each aggregated
+// error formatted with %+v, except that the callstacks of an *Err that are
+// identical to those of an earlier *Err are not repeated.  An *Err wrapped
+// by an aggregated error that is not itself an *Err is printed too.
+func (m *MultiErr) Format(s fmt.State, verb rune) {
+	switch verb {
+	case 'v':
+		if s.Flag('+') {
+			io.WriteString(s, m.render(
+				func(e *Err) string { return fmt.Sprintf("%+v", e) },
+				layerStacks,
+			))
+			return
+		}
+		io.WriteString(s, m.Error())
+	case 's':
+		io.WriteString(s, m.Error())
+	case 'q':
+		fmt.Fprintf(s, "%q", m.Error())
+	}
+}
+
+// render returns a string describing each aggregated error in turn, using
+// describe to describe each *Err whose callstacks, as returned by stacks,
+// have not already been described.
+func (m *MultiErr) render(describe, stacks func(*Err) string) string {
+	buf := bytes.Buffer{}
+	fmt.Fprintf(&buf, "%d errors occurred:\n", len(m.Errs))
+
+	// index of the first *Err with each callstack
+	seen := make(map[string]int)
+	for i, err := range m.Errs {
+		fmt.Fprintf(&buf, "error %d of %d: ", i+1, len(m.Errs))
+		if err == nil {
+			buf.WriteString(nilType + "\n")
+			continue
+		}
+
+		e, ok := err.(*Err)
+		if !ok {
+			if s, ok := err.(interface{ ErrorStack() string }); ok {
+				buf.WriteString(s.ErrorStack())
+				continue
+			}
+			fmt.Fprintf(&buf, "%s %s\n", reflect.TypeOf(err).String(), err.Error())
+			// an *Err wrapped by another error still has a stack to describe
+			if e, ok = As[*Err](err); !ok {
+				continue
+			}
+		}
+
+		key := stacks(e)
+		if first, ok := seen[key]; ok {
+			if e == err {
+				fmt.Fprintf(&buf, "%s %s\n%s", e.TypeName(), e.Error(), formatFields(e.Fields()))
+			}
+			fmt.Fprintf(&buf, "(callstack identical to error %d)\n", first+1)
+			continue
+		}
+		seen[key] = i
+		buf.WriteString(describe(e))
+	}
+
+	return buf.String()
+}
+
+// layerStacks returns the callstacks of each *Err in the chain of e, as
+// printed by its Format method with %+v.
+func layerStacks(e *Err) string {
+	buf := bytes.Buffer{}
+	for _, layer := range e.layers() {
+		buf.Write(layer.ParentStack())
+		if layer.ignoreNestedStack {
+			break
+		}
+	}
+	return buf.String()
+}
@@ -0,0 +1,22 @@
+package errors
+
+import "fmt"
+
+func ExampleJoin() {
+	// run several operations, collecting every failure
+	var errs []error
+	for _, name := range []string{"a", "b", "c"} {
+		errs = append(errs, func() error {
+			if name == "b" {
+				return nil
+			}
+			return Errorf("operation %s failed", name)
+		}())
+	}
+	// aggregate the failures into a single error
+	err := Join(errs...)
+	fmt.Println(err)
+	// Output:
+	// operation a failed
+	// operation c failed
+}
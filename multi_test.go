@@ -0,0 +1,174 @@
+package errors
+
+import (
+	stderrors "errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"strings"
+	"testing"
+)
+
+// error strings used in this file
+const (
+	errJoinNotNil        = "joining only nil errors did not return nil"
+	errMembersIncorrect  = "the aggregated errors are not correct"
+	errMemberNotRendered = "an aggregated error was not rendered"
+	errStackNotDeduped   = "an identical stack was rendered more than once"
+	errMemberNotMatched  = "an aggregated error was not matched"
+)
+
+// error format strings used in this file
+const (
+	joinFailed = "Join() failed; %v"
+)
+
+func newMultiErr() (*MultiErr, []*Err) {
+	var errs []*Err
+	for i := 0; i < 3; i++ {
+		// each error created on the same line shares a stack
+		errs = append(errs, Errorf("failure %d", i))
+	}
+	errs = append(errs, New(io.EOF))
+	return Join(errs[0], nil, errs[1], errs[2], errs[3], fs.ErrNotExist).(*MultiErr), errs
+}
+
+func TestJoin(t *testing.T) {
+	// test case: only nil errors
+	if Join() != nil || Join(nil, nil) != nil {
+		t.Errorf(joinFailed, errJoinNotNil)
+	}
+
+	// test case: nil errors are ignored
+	m, errs := newMultiErr()
+	if len(m.Errs) != 5 || m.Errs[0] != errs[0] || m.Errs[4] != fs.ErrNotExist {
+		t.Errorf(joinFailed, errMembersIncorrect)
+	}
+	if len(m.Unwrap()) != 5 {
+		t.Errorf(joinFailed, errMembersIncorrect)
+	}
+
+	// test case: message contains every member's message
+	if m.Error() != "failure 0\nfailure 1\nfailure 2\nEOF\nfile does not exist" {
+		t.Errorf(joinFailed, errWrongErrorMessage)
+	}
+}
+
+func TestMultiErrIs(t *testing.T) {
+	m, errs := newMultiErr()
+
+	for _, target := range []error{errs[1], io.EOF, fs.ErrNotExist} {
+		if !Is(m, target) || !stderrors.Is(m, target) {
+			t.Errorf(joinFailed, errMemberNotMatched)
+		}
+		if !Is(New(m), target) {
+			t.Errorf(joinFailed, errMemberNotMatched)
+		}
+	}
+	if Is(m, io.ErrUnexpectedEOF) {
+		t.Errorf(evaluatedIncorrectlySame, "m", "io.ErrUnexpectedEOF")
+	}
+
+	if e, ok := As[*Err](m); !ok || e != errs[0] {
+		t.Errorf(joinFailed, errMemberNotMatched)
+	}
+	var pathErr *fs.PathError
+	if stderrors.As(m, &pathErr) {
+		t.Errorf(joinFailed, errFoundSpurious)
+	}
+}
+
+func TestMultiErrErrorStack(t *testing.T) {
+	m, errs := newMultiErr()
+
+	for name, s := range map[string]string{
+		"ErrorStack": m.ErrorStack(),
+		"%+v":        fmt.Sprintf("%+v", m),
+	} {
+		// the shared stack of the first three errors appears once, along with
+		// the distinct stack of the fourth
+		if strings.Count(s, string(errs[0].Stack())) != 1 {
+			t.Errorf(joinFailed, name+": "+errStackNotDeduped)
+		}
+		if !strings.Contains(s, string(errs[3].Stack())) {
+			t.Errorf(joinFailed, name+": "+errMemberNotRendered)
+		}
+		if strings.Count(s, "(callstack identical to error 1)") != 2 {
+			t.Errorf(joinFailed, name+": "+errStackNotDeduped)
+		}
+		for _, msg := range []string{"failure 0", "failure 1", "failure 2", "EOF", "file does not exist"} {
+			if !strings.Contains(s, msg) {
+				t.Errorf(joinFailed, name+": "+errMemberNotRendered)
+			}
+		}
+	}
+
+	if fmt.Sprintf("%v", m) != m.Error() || fmt.Sprintf("%q", m) != fmt.Sprintf("%q", m.Error()) {
+		t.Errorf(joinFailed, errWrongErrorMessage)
+	}
+}
+
+func TestMultiErrErrorStackLayers(t *testing.T) {
+	inner := New(io.EOF)
+	// wrapped on different lines, so that their own stacks differ
+	outer := []*Err{Wrap(inner, 0)}
+	outer = append(outer, Wrap(inner, 0))
+	m := Join(outer[0], outer[1]).(*MultiErr)
+
+	// the deepest stacks are the same, but %+v prints each layer's stack
+	s := fmt.Sprintf("%+v", m)
+	if !strings.Contains(s, string(outer[1].ParentStack())) || strings.Contains(s, "(callstack identical to error 1)") {
+		t.Errorf(joinFailed, "%+v: "+errMemberNotRendered)
+	}
+	if s = m.ErrorStack(); strings.Count(s, string(inner.Stack())) != 1 || !strings.Contains(s, "(callstack identical to error 1)") {
+		t.Errorf(joinFailed, "ErrorStack: "+errStackNotDeduped)
+	}
+}
+
+func TestMultiErrErrorStackWrapped(t *testing.T) {
+	inner := New(io.EOF)
+	m := Join(fmt.Errorf("reading: %w", inner), fmt.Errorf("again: %w", inner)).(*MultiErr)
+
+	for name, s := range map[string]string{
+		"ErrorStack": m.ErrorStack(),
+		"%+v":        fmt.Sprintf("%+v", m),
+	} {
+		if strings.Count(s, string(inner.Stack())) != 1 || !strings.Contains(s, "reading: EOF") || !strings.Contains(s, "again: EOF") {
+			t.Errorf(joinFailed, name+": "+errMemberNotRendered)
+		}
+		if !strings.Contains(s, "(callstack identical to error 1)") {
+			t.Errorf(joinFailed, name+": "+errStackNotDeduped)
+		}
+	}
+}
+
+func TestMultiErrErrorStackFields(t *testing.T) {
+	m, errs := newMultiErr()
+	m.Errs[1] = errs[1].With("user_id", 42)
+
+	// the fields of an *Err are printed even when its stack is not
+	for name, s := range map[string]string{
+		"ErrorStack": m.ErrorStack(),
+		"%+v":        fmt.Sprintf("%+v", m),
+	} {
+		if !strings.Contains(s, "failure 1\nuser_id=42\n(callstack identical to error 1)") {
+			t.Errorf(joinFailed, name+": "+errMemberNotRendered)
+		}
+	}
+}
+
+func TestMultiErrNil(t *testing.T) {
+	m := &MultiErr{Errs: []error{nil, io.EOF}}
+
+	if m.Error() != "<nil>\nEOF" {
+		t.Errorf(joinFailed, errWrongErrorMessage)
+	}
+	for name, s := range map[string]string{
+		"ErrorStack": m.ErrorStack(),
+		"%+v":        fmt.Sprintf("%+v", m),
+	} {
+		if !strings.Contains(s, "error 1 of 2: <nil>\n") || !strings.Contains(s, "error 2 of 2: *errors.errorString EOF\n") {
+			t.Errorf(joinFailed, name+": "+errMemberNotRendered)
+		}
+	}
+}
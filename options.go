@@ -0,0 +1,118 @@
+package errors
+
+// Option configures how New makes an *Err, such as how its stacktrace is
+// captured.
+type Option func(*options)
+
+// options holds the configuration built up by a list of Option.
+type options struct {
+	// how many additional frames to skip when capturing the stack
+	skip int
+	// the maximum number of frames to capture
+	depth int
+	// the number of frames, of depth, to capture from the bottom of a stack
+	// deeper than depth
+	tailDepth int
+	// whether to capture a stack at all
+	noStack bool
+	// the prefix to prepend to the error message
+	prefix string
+	// alternating keys and values to attach as fields
+	fields []interface{}
+	// the initial value of ignoreNestedStack
+	ignoreNestedStack bool
+}
+
+// defaultTailDepth is the number of frames captured from the bottom of a
+// stack too deep to be captured whole, unless the TailDepth option is given.
+const defaultTailDepth = 10
+
+// defaultOptions returns the configuration used where no options are given.
+func defaultOptions() options {
+	return options{depth: MaxStackDepth, tailDepth: defaultTailDepth}
+}
+
+// applyOptions returns the configuration built up by opts.
+func applyOptions(opts []Option) options {
+	if len(opts) == 0 {
+		// avoids o escaping to the heap in the common case
+		return defaultOptions()
+	}
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// Skip is an Option that starts the stacktrace n frames further up the
+// stack; Skip(0) has no effect, Skip(1) starts it from the caller of the
+// function calling New, etc.  A negative skip in total is treated as 0, so
+// that stacks never start within this package.
+func Skip(n int) Option {
+	return func(o *options) {
+		o.skip += n
+	}
+}
+
+// Depth is an Option that sets the maximum number of stackframes captured,
+// in place of MaxStackDepth.  Values less than one capture no stack.
+func Depth(n int) Option {
+	return func(o *options) {
+		if n < 0 {
+			n = 0
+		}
+		o.depth = n
+	}
+}
+
+// TailDepth is an Option that sets how many of the stackframes captured are
+// taken from the bottom of a stack too deep to be captured whole, so that
+// the entry point of the goroutine is reported as well as the calls that led
+// to the error; 10 are taken by default.  The frames in between are elided,
+// and are reported as a single StackFrame marking how many were elided.  At
+// most half of the stackframes captured are taken from the bottom, and
+// values less than one take none.
+func TailDepth(n int) Option {
+	return func(o *options) {
+		if n < 0 {
+			n = 0
+		}
+		o.tailDepth = n
+	}
+}
+
+// NoStack is an Option that captures no stack at all, for errors that are
+// expected in normal operation and where the cost of capture is unwanted.
+// Functions that return information about the deepest nested stack will
+// still find the stacks of nested errors.
+func NoStack() Option {
+	return func(o *options) {
+		o.noStack = true
+	}
+}
+
+// IgnoreNestedStack is an Option that sets ignoreNestedStack, as described
+// by SetIgnoreNestedStack, so that the *Err reports its own stack rather
+// than that of the deepest nested *Err.
+func IgnoreNestedStack() Option {
+	return func(o *options) {
+		o.ignoreNestedStack = true
+	}
+}
+
+// Prefix is an Option that adds a prefix to the error message when calling
+// Error(), in the same way as Wrapf does.
+func Prefix(prefix string) Option {
+	return func(o *options) {
+		o.prefix = prefix
+	}
+}
+
+// Fields is an Option that attaches structured context, in the same way as
+// With does.
+func Fields(keyvals ...interface{}) Option {
+	return func(o *options) {
+		o.fields = append(o.fields, keyvals...)
+	}
+}
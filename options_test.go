@@ -0,0 +1,82 @@
+package errors
+
+import (
+	"io"
+	"reflect"
+	"testing"
+)
+
+// error strings used in this file
+const (
+	errOptionNotApplied = "the option was not applied"
+	errGlobalModified   = "the global MaxStackDepth was modified"
+)
+
+// error format strings used in this file
+const (
+	optionFailed = "New() with %v failed; %v"
+)
+
+//go:noinline
+func newSkippingHelper(opts ...Option) *Err {
+	return New(io.EOF, append([]Option{Skip(1)}, opts...)...)
+}
+
+func TestOptions(t *testing.T) {
+	// test case: Skip
+	bs := [][]uintptr{newSkippingHelper().stack, callers()}
+	if err := compareStacks(bs[0], bs[1]); err != nil {
+		t.Errorf(optionFailed, "Skip", errSkipFailed)
+		t.Error(err)
+	}
+	if len(newSkippingHelper(Skip(1)).stack) != len(callers())-1 {
+		t.Errorf(optionFailed, "Skip", errSkipFailed)
+	}
+
+	// test case: Depth
+	if len(New(io.EOF, Depth(2)).stack) != 2 || len(New(io.EOF, Depth(-1)).stack) != 0 {
+		t.Errorf(optionFailed, "Depth", errOptionNotApplied)
+	}
+	if MaxStackDepth != 50 || len(New(io.EOF).stack) != len(callers()) {
+		t.Errorf(optionFailed, "Depth", errGlobalModified)
+	}
+
+	// test case: TailDepth, below and beyond half the depth, of a stack
+	// deeper than the depth
+	atDepth(10, func() {
+		if _, at := New(io.EOF, Depth(4), TailDepth(1)).Elided(); at != 3 {
+			t.Errorf(optionFailed, "TailDepth", errOptionNotApplied)
+		}
+		if _, at := New(io.EOF, Depth(4), TailDepth(10)).Elided(); at != 2 {
+			t.Errorf(optionFailed, "TailDepth", errOptionNotApplied)
+		}
+		if elided, at := New(io.EOF, Depth(4), TailDepth(-1)).Elided(); at != 4 || elided != len(callers())-4 {
+			t.Errorf(optionFailed, "TailDepth", errOptionNotApplied)
+		}
+	})
+
+	// test case: NoStack, with and without a nested stack
+	inner := New(io.EOF)
+	if len(New(io.EOF, NoStack()).Callers()) != 0 {
+		t.Errorf(optionFailed, "NoStack", errOptionNotApplied)
+	}
+	if err := New(inner, NoStack()); len(err.ParentCallers()) != 0 || !reflect.DeepEqual(err.Callers(), inner.ParentCallers()) {
+		t.Errorf(optionFailed, "NoStack", errOptionNotApplied)
+	}
+
+	// test case: IgnoreNestedStack
+	if err := New(inner, IgnoreNestedStack()); !err.ignoreNestedStack || !reflect.DeepEqual(err.Callers(), err.ParentCallers()) {
+		t.Errorf(optionFailed, "IgnoreNestedStack", errOptionNotApplied)
+	}
+
+	// test case: Prefix matches Wrapf
+	if New(io.EOF, Prefix(testPrefixFoobar)).Error() != Wrapf(io.EOF, testPrefixFoobar, 0).Error() {
+		t.Errorf(optionFailed, "Prefix", errOptionNotApplied)
+	}
+
+	// test case: Fields matches With, and may be given several times
+	err := New(io.EOF, Fields("user_id", 42), Fields("shard", 3))
+	if !reflect.DeepEqual(err.Fields(), New(io.EOF).With("user_id", 42, "shard", 3).Fields()) {
+		t.Errorf(optionFailed, "Fields", errOptionNotApplied)
+	}
+}
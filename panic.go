@@ -0,0 +1,166 @@
+package errors
+
+import (
+	"strconv"
+	"strings"
+)
+
+// A *Panic is the underlying error of an *Err parsed by ParsePanic from the
+// output of a go program that panicked.  The TypeName of such an *Err is
+// "panic".
+type Panic struct {
+	// The Message of the panic, without any annotation such as
+	// " [recovered]"
+	Message string
+	// The Kind of runtime error that caused the panic, if the runtime
+	// panicked rather than the program
+	Kind PanicKind
+	// The Index that was out of range, and the Length of what was indexed,
+	// for a panic of kind IndexOutOfRange.  Either is -1 where go did not
+	// print it, as before go 1.12, and for the Length of a negative Index.
+	Index, Length int
+	// The Signal that caused the panic, if the runtime caught one, as it
+	// does for a nil pointer dereference
+	Signal *Signal
+	// The panic that was in progress, or had been recovered from, when this
+	// panic was raised by a deferred function, if any
+	Previous *Panic
+}
+
+func (p *Panic) Error() string {
+	return p.Message
+}
+
+// A PanicKind classifies the panics raised by the runtime, so that panics
+// with different messages but the same cause, such as indexing out of range
+// with different indices, can be grouped together.
+type PanicKind int
+
+const (
+	// OtherPanic is the kind of panics raised by the program, and of
+	// runtime errors not otherwise classified
+	OtherPanic PanicKind = iota
+	// NilDereference is the kind of panics caused by dereferencing a nil
+	// pointer, or an invalid address
+	NilDereference
+	// IndexOutOfRange is the kind of panics caused by indexing an array,
+	// slice or string out of its range
+	IndexOutOfRange
+	// DivideByZero is the kind of panics caused by an integer division by
+	// zero
+	DivideByZero
+	// TypeAssertion is the kind of panics caused by a failed type
+	// assertion or interface conversion
+	TypeAssertion
+)
+
+// String returns a description of the kind of panic, such as "nil pointer
+// dereference".
+func (kind PanicKind) String() string {
+	switch kind {
+	case NilDereference:
+		return "nil pointer dereference"
+	case IndexOutOfRange:
+		return "index out of range"
+	case DivideByZero:
+		return "integer divide by zero"
+	case TypeAssertion:
+		return "type assertion"
+	}
+	return "other"
+}
+
+// A Signal is a signal caught by the runtime that caused a go program to
+// panic or to crash with a fatal error, as described by a line such as
+// "[signal SIGSEGV: segmentation violation code=0x1 addr=0x0 pc=0x47db00]".
+type Signal struct {
+	// The Name of the signal, such as "SIGSEGV", or, as printed before go
+	// 1.5, its number, such as "0xb"
+	Name string
+	// The Description of the signal, such as "segmentation violation", if
+	// printed
+	Description string
+	// The signal's Code, which describes its cause, such as SEGV_MAPERR
+	Code uint64
+	// The faulting address, such as 0 for a nil pointer dereference
+	Addr uint64
+	// The program counter of the instruction that caused the signal
+	PC uint64
+}
+
+// classify sets the kind of the panic, and the details of its kind, from its
+// message.
+func (p *Panic) classify() {
+	message := p.Message
+	switch {
+	case strings.HasPrefix(message, "runtime error: invalid memory address or nil pointer dereference"):
+		p.Kind = NilDereference
+	case strings.HasPrefix(message, "runtime error: index out of range"):
+		p.Kind = IndexOutOfRange
+		p.Index, p.Length = parseIndexOutOfRange(strings.TrimPrefix(message, "runtime error: index out of range"))
+	case strings.HasPrefix(message, "runtime error: integer divide by zero"):
+		p.Kind = DivideByZero
+	case strings.HasPrefix(message, "interface conversion: "):
+		p.Kind = TypeAssertion
+	}
+}
+
+// parseIndexOutOfRange parses the details of an index out of range error,
+// such as " [5] with length 3", into the index and the length, either of
+// which is -1 if not given.
+func parseIndexOutOfRange(details string) (index, length int) {
+	index, length = -1, -1
+	if !strings.HasPrefix(details, " [") {
+		return index, length
+	}
+	end := strings.IndexByte(details, ']')
+	if end == -1 {
+		return index, length
+	}
+	if n, err := strconv.Atoi(details[2:end]); err == nil {
+		index = n
+	}
+	if rest := details[end+1:]; strings.HasPrefix(rest, " with length ") {
+		if n, err := strconv.Atoi(strings.TrimPrefix(rest, " with length ")); err == nil {
+			length = n
+		}
+	}
+	return index, length
+}
+
+// parseSignal parses a line describing the signal that caused a panic, such
+// as "[signal SIGSEGV: segmentation violation code=0x1 addr=0x0 pc=0x47db00]",
+// or, as printed before go 1.5, "[signal 0xb code=0x1 addr=0x0
+// pc=0x20be9]".
+func parseSignal(line string) (*Signal, bool) {
+	if !strings.HasPrefix(line, "[signal ") || !strings.HasSuffix(line, "]") {
+		return nil, false
+	}
+	line = line[len("[signal ") : len(line)-1]
+
+	s := &Signal{}
+	// the fields are found from the end, as the description contains spaces
+	for {
+		space := strings.LastIndexByte(line, ' ')
+		key, value, ok := strings.Cut(line[space+1:], "=")
+		if space == -1 || !ok {
+			break
+		}
+		n, err := strconv.ParseUint(value, 0, 64)
+		if err != nil {
+			return nil, false
+		}
+		switch key {
+		case "code":
+			s.Code = n
+		case "addr":
+			s.Addr = n
+		case "pc":
+			s.PC = n
+		}
+		line = line[:space]
+	}
+
+	s.Name, s.Description, _ = strings.Cut(line, ": ")
+	return s, true
+}
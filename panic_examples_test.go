@@ -0,0 +1,26 @@
+package errors
+
+import (
+	"fmt"
+)
+
+func ExamplePanic() {
+	output := `panic: runtime error: index out of range [5] with length 3
+
+goroutine 1 [running]:
+main.get(...)
+	/src/main.go:5
+main.main()
+	/src/main.go:9 +0x1d
+`
+
+	err, parseErr := ParsePanic(output)
+	if parseErr != nil {
+		panic(parseErr)
+	}
+	if p, ok := As[*Panic](err); ok && p.Kind == IndexOutOfRange {
+		fmt.Printf("%s: [%d] with length %d\n", p.Kind, p.Index, p.Length)
+	}
+	// Output:
+	// index out of range: [5] with length 3
+}
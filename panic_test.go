@@ -0,0 +1,100 @@
+package errors
+
+import (
+	"testing"
+)
+
+var nestedSignal = `panic: runtime error: invalid memory address or nil pointer dereference [recovered]
+	panic: cleaning up
+[signal SIGSEGV: segmentation violation code=0x1 addr=0x8 pc=0x47db00]
+
+goroutine 1 [running]:
+main.main()
+	/src/main.go:10 +0x15
+`
+
+var unexpectedSignal = `fatal error: unexpected signal during runtime execution
+[signal SIGSEGV: segmentation violation code=0x1 addr=0x63 pc=0x7f1c2a2e4b36]
+
+goroutine 1 [syscall]:
+runtime.cgocall(0x4a1b70, 0xc000059f58)
+	/usr/local/go/src/runtime/cgocall.go:157 +0x4b fp=0xc000059f30 sp=0xc000059ef8 pc=0x404e4b
+main.main()
+	/src/main.go:12 +0x25 fp=0xc000059f80 sp=0xc000059f58 pc=0x4a1ae5
+`
+
+func TestParsePanicSignal(t *testing.T) {
+	Err, err := ParsePanic(nestedSignal)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := Err.Underlying.(*Panic)
+	if p.Message != "cleaning up" || p.Kind != OtherPanic || p.Signal != nil {
+		t.Errorf("the last panic was parsed as %+v", p)
+	}
+	want := Signal{Name: "SIGSEGV", Description: "segmentation violation", Code: 1, Addr: 8, PC: 0x47db00}
+	if p.Previous == nil || p.Previous.Kind != NilDereference || p.Previous.Signal == nil || *p.Previous.Signal != want {
+		t.Errorf("the signal was not attached to the nil pointer dereference: %+v", p.Previous)
+	}
+	if frames := Err.StackFrames(); len(frames) != 1 || frames[0].Name != "main" {
+		t.Errorf("the stack parsed was:\n%s", frames)
+	}
+}
+
+func TestParsePanicFatalSignal(t *testing.T) {
+	Err, err := ParsePanic(unexpectedSignal)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fatal := Err.Underlying.(*FatalError)
+	if fatal.Signal == nil || fatal.Signal.Name != "SIGSEGV" || fatal.Signal.Addr != 0x63 || fatal.Signal.PC != 0x7f1c2a2e4b36 {
+		t.Errorf("the signal was parsed as %+v", fatal.Signal)
+	}
+}
+
+func TestParseSignal(t *testing.T) {
+	tests := []struct {
+		line string
+		want Signal
+	}{
+		{"[signal SIGSEGV: segmentation violation code=0x1 addr=0x0 pc=0x47db00]", Signal{Name: "SIGSEGV", Description: "segmentation violation", Code: 1, PC: 0x47db00}},
+		{"[signal SIGBUS: bus error code=0x2 addr=0xc000100000 pc=0x45d2a1]", Signal{Name: "SIGBUS", Description: "bus error", Code: 2, Addr: 0xc000100000, PC: 0x45d2a1}},
+		// go 1.4
+		{"[signal 0xb code=0x1 addr=0x0 pc=0x20be9]", Signal{Name: "0xb", Code: 1, PC: 0x20be9}},
+	}
+
+	for _, test := range tests {
+		signal, ok := parseSignal(test.line)
+		if !ok || *signal != test.want {
+			t.Errorf("%q was parsed as %+v", test.line, signal)
+		}
+	}
+
+	for _, line := range []string{"goroutine 1 [running]:", "[signal SIGSEGV: segmentation violation code=bad]"} {
+		if _, ok := parseSignal(line); ok {
+			t.Errorf("%q was parsed as a signal", line)
+		}
+	}
+}
+
+func TestParseIndexOutOfRange(t *testing.T) {
+	tests := []struct {
+		message       string
+		index, length int
+	}{
+		{"runtime error: index out of range [5] with length 3", 5, 3},
+		{"runtime error: index out of range [-1]", -1, -1},
+		// before go 1.12
+		{"runtime error: index out of range", -1, -1},
+	}
+
+	for _, test := range tests {
+		p := Panic{Message: test.message}
+		p.classify()
+		if p.Kind != IndexOutOfRange || p.Index != test.index || p.Length != test.length {
+			t.Errorf("%q was parsed as %s [%d] with length %d", test.message, p.Kind, p.Index, p.Length)
+		}
+	}
+}
@@ -5,70 +5,207 @@ import (
 	"strings"
 )
 
-type uncaughtPanic struct{ message string }
-
-func (p uncaughtPanic) Error() string {
-	return p.message
-}
+// panicSuffixes are the annotations go adds to the message of a panic that
+// was recovered from before a further panic was raised.
+var panicSuffixes = []string{" [recovered]", " [recovered, repanicked]"}
 
 // ParsePanic allows you to get an error object from the output of a go program
 // that panicked. This is particularly useful with https://github.com/mitchellh/panicwrap.
+// The frames of the stack parsed are kept as printed, as by StackFrames;
+// repeated frames are collapsed only where the stack is formatted, as by
+// Stack.
+//
+// It is tested against the output of go 1.17, 1.18, 1.20 and 1.27.  Where
+// further panics were raised by deferred functions while panicking, the
+// error message is that of the last of them.  The stack of the goroutine
+// that panicked is found wherever it is among the stacks of other
+// goroutines, as printed with GOTRACEBACK=all, and anything following it,
+// such as the "exit status 2" printed by go run, is ignored.
+//
+// The underlying error of the *Err returned is a *Panic, which classifies
+// panics raised by the runtime, such as nil pointer dereferences, and
+// describes the signal that caused the panic, if any.
+//
+// The output of a program that crashed with a fatal error, such as "fatal
+// error: concurrent map writes", is parsed into an *Err whose underlying
+// error is a *FatalError, as described there.
 func ParsePanic(text string) (*Err, error) {
-	lines := strings.Split(text, "\n")
+	lines := splitLines(text)
 
-	state := "seek"
-
-	var message string
-	var stack []StackFrame
+	// go explains some fatal errors, such as running out of memory, on
+	// lines of their own before the error
+	first := 0
+	for first < len(lines)-1 && strings.HasPrefix(lines[first], "runtime: ") {
+		first++
+	}
+	if strings.HasPrefix(lines[first], "fatal error: ") {
+		return parseFatalError(lines, first)
+	}
 
-	if strings.HasPrefix(lines[0], "panic: ") {
-		message = strings.TrimPrefix(lines[0], "panic: ")
-	} else {
+	if !strings.HasPrefix(lines[0], "panic: ") {
 		return nil, Errorf("bugsnag.panicParser: Invalid line (no prefix): %s", lines[0])
 	}
+	p, start := parsePanicMessages(lines)
+
+	for i := start; i < len(lines); i++ {
+		if _, status, ok := parseGoroutineHeader(lines[i]); !ok || !strings.HasPrefix(status, "running") {
+			continue
+		}
 
-	for i := 0; i < len(lines); i++ {
+		stack, creator, _, _, err := parseGoroutineStack(lines, i+1)
+		if err != nil {
+			return nil, err
+		}
+		if creator != nil {
+			stack = append(stack, *creator)
+		}
+		return &Err{Underlying: p, frames: resolvedFrameCache(stack)}, nil
+	}
+	return nil, Errorf("could not parse panic: %v", text)
+}
+
+// splitLines splits text into lines, whether they end in "\n" or "\r\n".
+func splitLines(text string) []string {
+	lines := strings.Split(text, "\n")
+	for i := range lines {
+		lines[i] = strings.TrimSuffix(lines[i], "\r")
+	}
+	return lines
+}
+
+// parseGoroutineStack parses the stack of a goroutine, starting at lines[i],
+// the line following its header, and ending at the blank line following it.
+// It returns the frames of the stack; the frame of the go statement that
+// created the goroutine, if any, and the ID of the goroutine that executed
+// it, if printed; and the index of the first line following the stack.
+func parseGoroutineStack(lines []string, i int) (stack []StackFrame, creator *StackFrame, creatorID int, next int, err error) {
+	for ; i < len(lines); i++ {
 		line := lines[i]
 
-		switch state {
-		case "seek":
-			if strings.HasPrefix(line, "goroutine ") && strings.HasSuffix(line, "[running]:") {
-				state = "parsing"
-			}
-		case "parsing":
-			if line == "" {
-				state = "done"
-				break
-			}
-			createdBy := false
-			if strings.HasPrefix(line, "created by ") {
-				line = strings.TrimPrefix(line, "created by ")
-				createdBy = true
+		if line == "" || strings.HasPrefix(line, "exit status ") {
+			return stack, nil, 0, i, nil
+		}
+		// the stack of a goroutine running on another thread, as printed on
+		// SIGQUIT, is not printed
+		if strings.HasPrefix(line, "\tgoroutine running on other thread") {
+			continue
+		}
+		if elided, ok := parseElided(line); ok {
+			stack = append(stack, StackFrame{Elided: elided})
+			continue
+		}
+		createdBy := false
+		if strings.HasPrefix(line, "created by ") {
+			line = strings.TrimPrefix(line, "created by ")
+			// go 1.21 on names the goroutine that created this one
+			if idx := strings.Index(line, " in goroutine "); idx > -1 {
+				creatorID, _ = strconv.Atoi(line[idx+len(" in goroutine "):])
+				line = line[:idx]
 			}
+			createdBy = true
+		}
 
-			i++
+		i++
 
-			if i >= len(lines) {
-				return nil, Errorf("bugsnag.panicParser: Invalid line (unpaired): %s", line)
-			}
+		if i >= len(lines) {
+			return nil, nil, 0, i, Errorf("bugsnag.panicParser: Invalid line (unpaired): %s", line)
+		}
 
-			frame, err := parsePanicFrame(line, lines[i], createdBy)
-			if err != nil {
-				return nil, err
-			}
+		frame, err := parsePanicFrame(line, lines[i], createdBy)
+		if err != nil {
+			return nil, nil, 0, i, err
+		}
+
+		if createdBy {
+			return stack, frame, creatorID, i + 1, nil
+		}
+		stack = append(stack, *frame)
+	}
+	return stack, nil, 0, i, nil
+}
+
+// parsePanicMessages parses the message of the panic on the first of lines,
+// and of any further panics raised by deferred functions, which follow it
+// indented.  It returns the last panic raised, and the index of the first
+// line following the messages and the signal that caused the panic, if any.
+func parsePanicMessages(lines []string) (*Panic, int) {
+	p := &Panic{Message: strings.TrimPrefix(lines[0], "panic: ")}
+
+	i := 1
+	for ; i < len(lines) && strings.HasPrefix(lines[i], "\t"); i++ {
+		line := lines[i][1:]
+		if strings.HasPrefix(line, "panic: ") {
+			p = &Panic{Message: strings.TrimPrefix(line, "panic: "), Previous: p}
+		} else {
+			// newer versions of go indent each further line of a message
+			// that spans several
+			p.Message += "\n" + line
+		}
+	}
 
-			stack = append(stack, *frame)
-			if createdBy {
-				state = "done"
-				break
+	for q := p; q != nil; q = q.Previous {
+		for _, suffix := range panicSuffixes {
+			q.Message = strings.TrimSuffix(q.Message, suffix)
+		}
+		q.classify()
+	}
+
+	// the signal follows the messages, and caused the last runtime error
+	// raised, rather than any panic raised by a deferred function since
+	if i < len(lines) {
+		if signal, ok := parseSignal(lines[i]); ok {
+			caused := p
+			for q := p; q != nil; q = q.Previous {
+				if strings.HasPrefix(q.Message, "runtime error: ") {
+					caused = q
+					break
+				}
 			}
+			caused.Signal = signal
+			i++
 		}
 	}
+	return p, i
+}
 
-	if state == "done" || state == "parsing" {
-		return &Err{Underlying: uncaughtPanic{message}, frames: stack}, nil
+// parseGoroutineHeader parses a line introducing the stack of a goroutine,
+// such as "goroutine 1 [running]:", "goroutine 1 [running, locked to
+// thread]:" or, as printed with GOTRACEBACK=system, "goroutine 1
+// gp=0xc000002380 m=0 mp=0x5d3f40 [running]:", and returns the ID of the
+// goroutine and its status within the brackets.
+func parseGoroutineHeader(line string) (id int, status string, ok bool) {
+	if !strings.HasPrefix(line, "goroutine ") || !strings.HasSuffix(line, "]:") {
+		return 0, "", false
 	}
-	return nil, Errorf("could not parse panic: %v", text)
+	open := strings.Index(line, " [")
+	if open == -1 {
+		return 0, "", false
+	}
+
+	fields := strings.Fields(line[len("goroutine "):open])
+	if len(fields) == 0 {
+		return 0, "", false
+	}
+	id, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return 0, "", false
+	}
+	return id, line[open+2 : len(line)-2], true
+}
+
+// parseElided parses a line marking frames elided from a deep stack, such
+// as "...12 frames elided...", and returns the number of frames elided, or
+// -1 if, as in the "...additional frames elided..." printed before go 1.21,
+// that is not given.
+func parseElided(line string) (int, bool) {
+	if !strings.HasPrefix(line, "...") || !strings.HasSuffix(line, " frames elided...") {
+		return 0, false
+	}
+	n, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(line, "..."), " frames elided..."))
+	if err != nil {
+		return -1, true
+	}
+	return n, true
 }
 
 // The lines we're passing look like this:
@@ -83,19 +220,6 @@ func parsePanicFrame(name string, line string, createdBy bool) (*StackFrame, err
 	if idx != -1 {
 		name = name[:idx]
 	}
-	pkg := ""
-
-	if lastslash := strings.LastIndex(name, "/"); lastslash >= 0 {
-		pkg += name[:lastslash] + "/"
-		name = name[lastslash+1:]
-	}
-	if period := strings.Index(name, "."); period >= 0 {
-		pkg += name[:period]
-		name = name[period+1:]
-	}
-
-	name = strings.Replace(name, "·", ".", -1)
-
 	if !strings.HasPrefix(line, "\t") {
 		return nil, Errorf("bugsnag.panicParser: Invalid line (no tab): %s", line)
 	}
@@ -106,8 +230,11 @@ func parsePanicFrame(name string, line string, createdBy bool) (*StackFrame, err
 	}
 	file := line[1:idx]
 
+	// the line number may be followed by the offset of the program counter
+	// within the function, and by its frame, as printed with
+	// GOTRACEBACK=system
 	number := line[idx+1:]
-	if idx = strings.Index(number, " +"); idx > -1 {
+	if idx = strings.Index(number, " "); idx > -1 {
 		number = number[:idx]
 	}
 
@@ -116,10 +243,10 @@ func parsePanicFrame(name string, line string, createdBy bool) (*StackFrame, err
 		return nil, Errorf("bugsnag.panicParser: Invalid line (bad line number): %s", line)
 	}
 
-	return &StackFrame{
+	frame := &StackFrame{
 		File:       file,
 		LineNumber: int(lno),
-		Package:    pkg,
-		Name:       name,
-	}, nil
+	}
+	frame.setFuncName(name)
+	return frame, nil
 }
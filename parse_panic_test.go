@@ -1,7 +1,10 @@
 package errors
 
 import (
+	"io/ioutil"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -138,14 +141,14 @@ net.runtime_pollWait(0x911c30, 0x72, 0x0)
 net.(*pollDesc).Wait(0xc2080ba990, 
 `
 
-var result = []StackFrame{
-	{File: "/0/c/go/src/pkg/runtime/panic.c", LineNumber: 279, Name: "panic", Package: "runtime"},
-	{File: "/0/go/src/github.com/loopj/bugsnag-example-apps/go/revelapp/app/controllers/app.go", LineNumber: 13, Name: "func.001", Package: "github.com/loopj/bugsnag-example-apps/go/revelapp/app/controllers"},
-	{File: "/0/c/go/src/pkg/net/http/server.go", LineNumber: 1698, Name: "(*Server).Serve", Package: "net/http"},
+var result = Stack{
+	{File: "/0/c/go/src/pkg/runtime/panic.c", LineNumber: 279, Name: "panic", Package: "runtime", Function: "panic"},
+	{File: "/0/go/src/github.com/loopj/bugsnag-example-apps/go/revelapp/app/controllers/app.go", LineNumber: 13, Name: "func.001", Package: "github.com/loopj/bugsnag-example-apps/go/revelapp/app/controllers", Closure: "func.001"},
+	{File: "/0/c/go/src/pkg/net/http/server.go", LineNumber: 1698, Name: "(*Server).Serve", Package: "net/http", Receiver: "*Server", Function: "Serve"},
 }
 
 var resultCreatedBy = append(result,
-	StackFrame{File: "/0/go/src/github.com/loopj/bugsnag-example-apps/go/revelapp/app/controllers/app.go", LineNumber: 14, Name: "App.Index", Package: "github.com/loopj/bugsnag-example-apps/go/revelapp/app/controllers", ProgramCounter: 0x0})
+	StackFrame{File: "/0/go/src/github.com/loopj/bugsnag-example-apps/go/revelapp/app/controllers/app.go", LineNumber: 14, Name: "App.Index", Package: "github.com/loopj/bugsnag-example-apps/go/revelapp/app/controllers", Receiver: "App", Function: "Index", ProgramCounter: 0x0})
 
 func TestParsePanic(t *testing.T) {
 
@@ -205,3 +208,254 @@ func TestParsePanic(t *testing.T) {
 		}
 	}
 }
+
+var generics = `panic: hello!
+
+goroutine 1 [running]:
+example.com/a.(*T[...]).M(...)
+	/src/a/a.go:12
+example.com/a.F[...].func1()
+	/src/a/a.go:20 +0x1d
+main.main()
+	/src/main.go:9 +0x25
+`
+
+func TestParsePanicFuncName(t *testing.T) {
+	Err, err := ParsePanic(generics)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	frames := Err.StackFrames()
+	if frames[0].Receiver != "*T" || frames[0].Function != "M" || frames[0].TypeParams != "..." {
+		t.Errorf("the generic method was parsed as %+v", frames[0])
+	}
+	if frames[1].Function != "F" || frames[1].Closure != "func1" || frames[1].TypeParams != "..." {
+		t.Errorf("the closure was parsed as %+v", frames[1])
+	}
+}
+
+var recursive = `panic: hello!
+
+goroutine 1 [running]:
+main.parse(0x0)
+	/src/main.go:5 +0x25
+main.parse(0x1)
+	/src/main.go:7 +0x1d
+main.parse(0x2)
+	/src/main.go:7 +0x1d
+main.parse(0x3)
+	/src/main.go:7 +0x1d
+main.main()
+	/src/main.go:11 +0x25
+`
+
+func TestParsePanicCollapsed(t *testing.T) {
+	Err, err := ParsePanic(recursive)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the frames are kept as printed, and collapsed only when formatted
+	if frames := Err.StackFrames(); len(frames) != 5 {
+		t.Errorf("the recursive frames were not kept: %+v", frames)
+	}
+	if !strings.Contains(string(Err.Stack()), "... previous frame repeated 3 times ...") {
+		t.Errorf("the recursive frames were not collapsed: %s", Err.Stack())
+	}
+}
+
+// the expected results of parsing the panics in testdata/panics and the
+// fatal errors in testdata/fatal, each printed by, and named for, the version
+// of go that ran the program.  The frames counted are those describing
+// functions, not those marking frames elided.  go1.20-elided.txt was printed
+// with GOTRACEBACK=system, as go 1.20 counts only the frames it prints
+// against its limit of 100, and so only marks frames elided where it hides no
+// runtime frames.
+var parseFixtures = map[string]struct {
+	message     string
+	frames      int
+	top, bottom string
+	// whether a Signal was parsed
+	signal bool
+
+	// the details of a *Panic
+	kind          PanicKind
+	index, length int
+
+	// the details of a *FatalError
+	details    []string
+	goroutines int
+}{
+	"panics/go1.17-created.txt":            {message: "something went wrong", frames: 2, top: "work", bottom: "main"},
+	"panics/go1.18-recovered.txt":          {message: "second", frames: 4, top: "handle.func1", bottom: "main"},
+	"panics/go1.20-elided.txt":             {message: "too deep", frames: 100, top: "panic", bottom: "parse"},
+	"panics/go1.27-created.txt":            {message: "something went wrong", frames: 2, top: "work", bottom: "main"},
+	"panics/go1.27-elided.txt":             {message: "too deep", frames: 100, top: "parse", bottom: "main"},
+	"panics/go1.27-gotraceback-all.txt":    {message: "with others", frames: 1, top: "main", bottom: "main"},
+	"panics/go1.27-gotraceback-system.txt": {message: "locked", frames: 4, top: "panic", bottom: "goexit"},
+	"panics/go1.27-locked.txt":             {message: "locked", frames: 1, top: "main", bottom: "main"},
+	"panics/go1.27-multiline.txt":          {message: "first line\nsecond line", frames: 1, top: "main", bottom: "main"},
+	"panics/go1.27-nested.txt":             {message: "second", frames: 4, top: "handle.func1", bottom: "main"},
+	"panics/go1.27-recovered.txt":          {message: "second", frames: 4, top: "handle.func1", bottom: "main"},
+	"panics/go1.27-repanicked.txt":         {message: "first", frames: 4, top: "handle.func1", bottom: "main"},
+
+	"panics/go1.27-divide-by-zero.txt": {
+		message: "runtime error: integer divide by zero", frames: 2, top: "divide", bottom: "main",
+		kind: DivideByZero,
+	},
+	"panics/go1.27-index-out-of-range.txt": {
+		message: "runtime error: index out of range [5] with length 3", frames: 2, top: "get", bottom: "main",
+		kind: IndexOutOfRange, index: 5, length: 3,
+	},
+	"panics/go1.27-nil-dereference.txt": {
+		message: "runtime error: invalid memory address or nil pointer dereference", frames: 2, top: "get", bottom: "main",
+		signal: true, kind: NilDereference,
+	},
+	"panics/go1.27-type-assertion.txt": {
+		message: "interface conversion: interface {} is string, not int", frames: 2, top: "toInt", bottom: "main",
+		kind: TypeAssertion,
+	},
+
+	"fatal/go1.27-concurrent-map-writes.txt": {
+		message: "concurrent map writes", frames: 3, top: "fatal", bottom: "main",
+		goroutines: 1,
+	},
+	"fatal/go1.27-deadlock.txt": {
+		message: "all goroutines are asleep - deadlock!", frames: 1, top: "main", bottom: "main",
+		goroutines: 1,
+	},
+	"fatal/go1.27-out-of-memory.txt": {
+		message: "out of memory", frames: 9, top: "throw", bottom: "goexit",
+		details:    []string{"out of memory: cannot allocate 1099511627776-byte block (3932160 in use)"},
+		goroutines: 4,
+	},
+	"fatal/go1.27-stack-overflow.txt": {
+		message: "stack overflow", frames: 100, top: "recurse", bottom: "goexit",
+		details: []string{
+			"goroutine stack exceeds 1000000000-byte limit",
+			"sp=0x17ddfaa60390 stack=[0x17ddfaa60000, 0x17de1aa60000]",
+		},
+		goroutines: 4,
+	},
+}
+
+func TestParsePanicFixtures(t *testing.T) {
+	var files []string
+	for _, dir := range []string{"panics", "fatal"} {
+		found, err := filepath.Glob(filepath.Join("testdata", dir, "*.txt"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, found...)
+	}
+	if len(files) != len(parseFixtures) {
+		t.Errorf("%d fixtures were found, rather than %d", len(files), len(parseFixtures))
+	}
+
+	for _, file := range files {
+		want, ok := parseFixtures[filepath.ToSlash(strings.TrimPrefix(file, "testdata"+string(filepath.Separator)))]
+		if !ok {
+			t.Errorf("%s has no expected result", file)
+			continue
+		}
+		text, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		Err, err := ParsePanic(string(text))
+		if err != nil {
+			t.Errorf("%s: %v", file, err)
+			continue
+		}
+		if Err.Error() != want.message {
+			t.Errorf("%s: parsed as %s %q", file, Err.TypeName(), Err.Error())
+		}
+
+		var signal *Signal
+		switch u := Err.Underlying.(type) {
+		case *Panic:
+			signal = u.Signal
+			if Err.TypeName() != "panic" || u.Kind != want.kind || u.Index != want.index || u.Length != want.length {
+				t.Errorf("%s: parsed as %s %+v", file, Err.TypeName(), u)
+			}
+		case *FatalError:
+			signal = u.Signal
+			if Err.TypeName() != "fatal error" || len(u.Goroutines) != want.goroutines {
+				t.Errorf("%s: the goroutines were not attached: %+v", file, u)
+			}
+			if !reflect.DeepEqual(u.Details, want.details) {
+				t.Errorf("%s: the details parsed were %q", file, u.Details)
+			}
+		default:
+			t.Errorf("%s: the underlying error was a %T", file, Err.Underlying)
+		}
+		if (signal != nil) != want.signal {
+			t.Errorf("%s: the signal parsed was %+v", file, signal)
+		}
+
+		var frames Stack
+		for _, frame := range Err.StackFrames() {
+			if frame.Elided == 0 {
+				frames = append(frames, frame)
+			}
+		}
+		if len(frames) != want.frames {
+			t.Errorf("%s: %d frames were parsed, rather than %d", file, len(frames), want.frames)
+		}
+		if len(frames) == 0 || frames[0].Name != want.top || frames[len(frames)-1].Name != want.bottom {
+			t.Errorf("%s: the stack parsed was:\n%s", file, frames)
+		}
+	}
+}
+
+func TestParsePanicPrevious(t *testing.T) {
+	for _, file := range []string{"go1.18-recovered.txt", "go1.27-nested.txt", "go1.27-recovered.txt"} {
+		text, err := ioutil.ReadFile(filepath.Join("testdata", "panics", file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		Err, err := ParsePanic(string(text))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		p := Err.Underlying.(*Panic)
+		if p.Previous == nil || p.Previous.Message != "first" || p.Previous.Previous != nil {
+			t.Errorf("%s: the panic raised before the last was not kept", file)
+		}
+	}
+}
+
+func TestParsePanicElided(t *testing.T) {
+	tests := map[string]StackFrame{
+		"go1.27-elided.txt": {Elided: 102},
+		// go before 1.21 did not count the frames elided, from the bottom of
+		// the stack
+		"go1.20-elided.txt": {Elided: -1},
+	}
+
+	for file, marker := range tests {
+		text, err := ioutil.ReadFile(filepath.Join("testdata", "panics", file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		Err, err := ParsePanic(string(text))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		frames := Err.StackFrames()
+		marked := false
+		for _, frame := range frames {
+			marked = marked || frame == marker
+		}
+		if !marked || !strings.Contains(string(Err.Stack()), "\n"+marker.String()) {
+			t.Errorf("%s: the frames elided from the panic were not marked:\n%s", file, Err.Stack())
+		}
+		if file == "go1.20-elided.txt" && frames[len(frames)-1] != marker {
+			t.Errorf("%s: the frames elided were not marked at the bottom of the stack:\n%s", file, Err.Stack())
+		}
+	}
+}
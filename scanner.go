@@ -0,0 +1,363 @@
+package errors
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+)
+
+const (
+	// maxScannedLine is the length of the longest line read whole by a
+	// PanicScanner; the rest of longer lines is skipped.
+	maxScannedLine = 64 << 10
+	// maxScannedPanic is the size of the largest panic read whole by a
+	// PanicScanner; the stacks of goroutines printed beyond it are skipped.
+	maxScannedPanic = 4 << 20
+	// maxInterleavedLines is the number of lines in a row of other sources
+	// that may be interleaved with the lines of a panic before it is taken
+	// to have ended.
+	maxInterleavedLines = 64
+	// maxHeaderDistance is the number of lines following the first line of a
+	// panic, including interleaved lines, within which the header of a
+	// goroutine's stack must be found for it to be taken as a panic.
+	maxHeaderDistance = 8
+	// maxDetailLines is the number of lines beginning "runtime: " kept from
+	// before a fatal error, which go prints to explain some fatal errors.
+	maxDetailLines = 8
+)
+
+// fatalMarker begins the first line of a fatal error, which may follow lines
+// beginning with detailMarker.
+var fatalMarker, detailMarker = []byte("fatal error: "), []byte("runtime: ")
+
+// panicMarkers are the text that begins the output of a go program that
+// panicked or crashed with a fatal error.
+var panicMarkers = [][]byte{[]byte("panic: "), fatalMarker}
+
+// panicHeaders are the beginnings of the lines that go prints to head a
+// stack, shortly after the first line of a panic.
+var panicHeaders = [][]byte{[]byte("goroutine "), []byte("runtime stack:")}
+
+// panicLinePrefixes are the beginnings of the lines, other than those of
+// frames, that go prints while panicking.
+var panicLinePrefixes = [][]byte{
+	[]byte("goroutine "),
+	[]byte("created by "),
+	[]byte("..."),
+	[]byte("[signal "),
+	[]byte("runtime stack:"),
+	[]byte("runtime: "),
+	[]byte("exit status "),
+}
+
+// A PanicScanner finds the panics and fatal errors printed by go programs
+// within a stream of text, such as a log file, in which they are mixed with
+// unrelated lines, and parses each with ParsePanic.  The lines of a panic may
+// carry a prefix, such as a timestamp, so long as every line's prefix is the
+// same length and differs only in its digits.  Lines of other sources may be
+// interleaved with those of a panic.
+//
+// A PanicScanner reads its input a line at a time, and holds at most one
+// panic in memory, so scans input of any size in bounded memory.  Overlong
+// lines are cut short, and the stacks of goroutines of overlong panics, such
+// as those printed with GOTRACEBACK=all by a program with very many
+// goroutines, are skipped.  Text that looks like the start of a panic but
+// cannot be parsed, such as a log message mentioning a panic, is skipped, as
+// is any that is not soon followed by the header of a goroutine's stack.
+//
+// Use it in the same way as a bufio.Scanner:
+//
+//	scanner := errors.NewPanicScanner(file)
+//	for scanner.Scan() {
+//		report(scanner.Panic())
+//	}
+//	if err := scanner.Err(); err != nil {
+//		...
+//	}
+type PanicScanner struct {
+	r *bufio.Reader
+	// the offset of the next line to be read
+	offset int64
+
+	// the last line read, without its line ending, and the offset at which
+	// it starts
+	line      []byte
+	lineStart int64
+	// whether line is to be scanned again, having ended the panic before it
+	unread bool
+
+	// the lines beginning "runtime: " read immediately before line, without
+	// their prefix, which is detailsPrefix
+	details       []detailLine
+	detailsPrefix []byte
+
+	// the prefix of the lines of the panic being read
+	prefix []byte
+	// the text of the panic being read, without the prefixes of its lines
+	text bytes.Buffer
+	// the length of text up to the last blank line, which ends the stack of
+	// a goroutine
+	complete int
+	// whether the panic is too large for any more of it to be kept
+	full bool
+	// the number of interleaved lines since the last line of the panic
+	interleaved int
+	// the number of lines read since the first line of the panic, and
+	// whether the header of a stack has been found among them
+	read   int
+	header bool
+
+	panic      *Err
+	start, end int64
+	err        error
+}
+
+// NewPanicScanner returns a PanicScanner reading from r.
+func NewPanicScanner(r io.Reader) *PanicScanner {
+	return &PanicScanner{r: bufio.NewReaderSize(r, maxScannedLine)}
+}
+
+// Scan advances the scanner to the next panic, which is then available
+// through Panic and Offsets.  It returns false when the input ends, or when
+// reading it fails, in which case Err returns the error.
+func (s *PanicScanner) Scan() bool {
+	s.panic = nil
+	inPanic := false
+	for {
+		if !s.unread && !s.readLine() {
+			return inPanic && s.finish()
+		}
+		s.unread = false
+
+		if !inPanic {
+			inPanic = s.begin()
+			continue
+		}
+		if s.add() {
+			continue
+		}
+
+		// the line that ended the panic may begin the next
+		s.unread = true
+		inPanic = false
+		if s.finish() {
+			return true
+		}
+	}
+}
+
+// Panic returns the panic found by the last call to Scan.
+func (s *PanicScanner) Panic() *Err {
+	return s.panic
+}
+
+// Offsets returns the offsets within the input of the start of the first
+// line of the panic found by the last call to Scan, including its prefix,
+// and of the end of its last line.  Lines of other sources interleaved with
+// those of the panic lie between the two.  The first line of a fatal error
+// is the first of the lines beginning "runtime: " that go printed before it
+// to explain it, if any.
+func (s *PanicScanner) Offsets() (start, end int64) {
+	return s.start, s.end
+}
+
+// Err returns the first error, other than io.EOF, encountered while reading
+// the input.
+func (s *PanicScanner) Err() error {
+	return s.err
+}
+
+// readLine reads the next line of the input, and reports whether there was
+// one.
+func (s *PanicScanner) readLine() bool {
+	if s.err != nil {
+		return false
+	}
+
+	line, err := s.r.ReadSlice('\n')
+	s.line = append(s.line[:0], line...)
+	n := len(line)
+	for err == bufio.ErrBufferFull {
+		line, err = s.r.ReadSlice('\n')
+		n += len(line)
+	}
+	if err != nil && err != io.EOF {
+		s.err = err
+		return false
+	}
+	if n == 0 {
+		return false
+	}
+
+	s.lineStart = s.offset
+	s.offset += int64(n)
+	s.line = bytes.TrimSuffix(bytes.TrimSuffix(s.line, []byte("\n")), []byte("\r"))
+	return true
+}
+
+// begin begins a panic, if the line read is the first of one, and reports
+// whether it did.
+func (s *PanicScanner) begin() bool {
+	at := markerIndex(s.line)
+	if at == -1 {
+		s.keepDetail()
+		return false
+	}
+
+	s.prefix = append(s.prefix[:0], s.line[:at]...)
+	s.text.Reset()
+	s.start, s.end = s.lineStart, s.offset
+	// the details go printed before a fatal error begin it
+	if len(s.details) > 0 && bytes.HasPrefix(s.line[at:], fatalMarker) && sameShape(s.detailsPrefix, s.prefix) {
+		for _, detail := range s.details {
+			s.text.Write(detail.text)
+			s.text.WriteByte('\n')
+		}
+		s.start = s.details[0].start
+	}
+	s.details = s.details[:0]
+
+	s.text.Write(s.line[at:])
+	s.text.WriteByte('\n')
+	s.complete, s.full, s.interleaved = 0, false, 0
+	s.read, s.header = 0, false
+	return true
+}
+
+// A detailLine is a line beginning "runtime: " kept by a PanicScanner, as
+// one that may begin a fatal error.
+type detailLine struct {
+	// the line, without its prefix
+	text []byte
+	// the offset of the line, including its prefix
+	start int64
+}
+
+// keepDetail keeps the line read, which does not begin a panic, if it begins
+// with "runtime: ", as one of the lines that go prints before some fatal
+// errors to explain them; and otherwise discards the lines kept.
+func (s *PanicScanner) keepDetail() {
+	at := bytes.Index(s.line, detailMarker)
+	if at == -1 || len(s.details) > 0 && !sameShape(s.line[:at], s.detailsPrefix) {
+		s.details = s.details[:0]
+	}
+	if at == -1 {
+		return
+	}
+
+	if len(s.details) == 0 {
+		s.detailsPrefix = append(s.detailsPrefix[:0], s.line[:at]...)
+	}
+	if len(s.details) == maxDetailLines {
+		// only the last lines are kept, as those immediately before the
+		// fatal error
+		copy(s.details, s.details[1:])
+		s.details = s.details[:len(s.details)-1]
+	}
+	s.details = append(s.details, detailLine{text: append([]byte(nil), s.line[at:]...), start: s.lineStart})
+}
+
+// add adds the line read to the panic being read, and reports whether it
+// was part of the panic, or was an interleaved line of another source.  A
+// line of another source that itself begins a panic ends the panic being
+// read, as it was not one if its lines have a prefix of a different shape,
+// as does one read before a stack is found within maxHeaderDistance lines.
+func (s *PanicScanner) add() bool {
+	if s.read++; !s.header && s.read > maxHeaderDistance {
+		return false
+	}
+	n := len(s.prefix)
+	if len(s.line) < n || !sameShape(s.line[:n], s.prefix) {
+		if markerIndex(s.line) >= 0 {
+			return false
+		}
+		s.interleaved++
+		return s.interleaved <= maxInterleavedLines
+	}
+	line := s.line[n:]
+	if !isPanicLine(line) {
+		return false
+	}
+	s.interleaved = 0
+	for _, header := range panicHeaders {
+		if bytes.HasPrefix(line, header) {
+			s.header = true
+		}
+	}
+
+	if len(line) > 0 {
+		s.end = s.offset
+	}
+	if s.full {
+		return true
+	}
+	if s.text.Len()+len(line)+1 > maxScannedPanic {
+		// only whole stacks are kept, so that the text can be parsed
+		s.text.Truncate(s.complete)
+		s.full = true
+		return true
+	}
+	if len(line) == 0 {
+		s.complete = s.text.Len()
+	}
+	s.text.Write(line)
+	s.text.WriteByte('\n')
+	return true
+}
+
+// finish parses the panic read, and reports whether it could be.
+func (s *PanicScanner) finish() bool {
+	err, parseErr := ParsePanic(s.text.String())
+	if parseErr != nil {
+		return false
+	}
+	s.panic = err
+	return true
+}
+
+// markerIndex returns the index of the first of panicMarkers within line, or
+// -1 if there is none.
+func markerIndex(line []byte) int {
+	at := -1
+	for _, marker := range panicMarkers {
+		if i := bytes.Index(line, marker); i >= 0 && (at == -1 || i < at) {
+			at = i
+		}
+	}
+	return at
+}
+
+// isPanicLine reports whether line, without its prefix, is one that go
+// prints while panicking, following the panic's first line.
+func isPanicLine(line []byte) bool {
+	if len(line) == 0 || line[0] == '\t' {
+		return true
+	}
+	for _, prefix := range panicLinePrefixes {
+		if bytes.HasPrefix(line, prefix) {
+			return true
+		}
+	}
+	// the call of a frame, such as "main.main()" or "main.(*T).M(...)"
+	open := bytes.IndexByte(line, '(')
+	return open > 0 && bytes.IndexByte(line[:open], ' ') == -1 && line[len(line)-1] == ')'
+}
+
+// sameShape reports whether a and b are the same but for their digits, as
+// are the timestamps of different lines of a log.
+func sameShape(a, b []byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] && !(isDigit(a[i]) && isDigit(b[i])) {
+			return false
+		}
+	}
+	return true
+}
+
+// isDigit reports whether c is a decimal digit.
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
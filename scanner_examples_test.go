@@ -0,0 +1,31 @@
+package errors
+
+import (
+	"fmt"
+	"strings"
+)
+
+func ExamplePanicScanner() {
+	log := `12:00:01 listening on :8080
+12:00:02 panic: runtime error: invalid memory address or nil pointer dereference
+12:00:02 [signal SIGSEGV: segmentation violation code=0x1 addr=0x0 pc=0x47db00]
+12:00:02 
+12:00:02 goroutine 1 [running]:
+12:00:02 main.get(0x0?)
+12:00:02 	/src/main.go:7 +0x20
+12:00:02 main.main()
+12:00:02 	/src/main.go:11 +0x15
+12:00:03 listening on :8080
+`
+
+	scanner := NewPanicScanner(strings.NewReader(log))
+	for scanner.Scan() {
+		start, end := scanner.Offsets()
+		fmt.Printf("%s at bytes %d to %d, in %s\n", scanner.Panic().TypeName(), start, end, scanner.Panic().StackFrames()[0].Name)
+	}
+	if err := scanner.Err(); err != nil {
+		panic(err)
+	}
+	// Output:
+	// panic at bytes 28 to 339, in get
+}
@@ -0,0 +1,186 @@
+package errors
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+var scannedLog = `2024-01-02T03:04:05Z starting server
+2024-01-02T03:04:06Z recovered from panic: request cancelled
+2024-01-02T03:04:07Z panic: runtime error: index out of range [5] with length 3
+2024-01-02T03:04:07Z 
+2024-01-02T03:04:07Z goroutine 7 [running]:
+2024-01-02T03:04:07Z main.get(...)
+other-service: handled request
+2024-01-02T03:04:07Z 	/src/main.go:5
+2024-01-02T03:04:07Z main.handle(0xc000012345)
+2024-01-02T03:04:07Z 	/src/main.go:12 +0x1d
+2024-01-02T03:04:07Z created by main.main in goroutine 1
+2024-01-02T03:04:07Z 	/src/main.go:20 +0x25
+2024-01-02T03:04:08Z starting server
+2024-01-02T03:04:09Z fatal error: all goroutines are asleep - deadlock!
+2024-01-02T03:04:09Z 
+2024-01-02T03:04:09Z goroutine 1 [chan receive]:
+2024-01-02T03:04:09Z main.main()
+2024-01-02T03:04:09Z 	/src/main.go:25 +0x25
+`
+
+func TestPanicScanner(t *testing.T) {
+	scanner := NewPanicScanner(strings.NewReader(scannedLog))
+
+	want := []struct {
+		typeName, message string
+		top               string
+		first, last       string
+	}{
+		{"panic", "runtime error: index out of range [5] with length 3", "get", "2024-01-02T03:04:07Z panic: ", "/src/main.go:20 +0x25\n"},
+		{"fatal error", "all goroutines are asleep - deadlock!", "main", "2024-01-02T03:04:09Z fatal error: ", "/src/main.go:25 +0x25\n"},
+	}
+	i := 0
+	for ; scanner.Scan(); i++ {
+		if i >= len(want) {
+			t.Fatalf("too many panics were found: %v", scanner.Panic())
+		}
+		Err := scanner.Panic()
+		if Err.TypeName() != want[i].typeName || Err.Error() != want[i].message {
+			t.Errorf("panic %d was parsed as %s %q", i, Err.TypeName(), Err.Error())
+		}
+		if frames := Err.StackFrames(); len(frames) == 0 || frames[0].Name != want[i].top {
+			t.Errorf("the stack of panic %d was parsed as:\n%s", i, frames)
+		}
+
+		start, end := scanner.Offsets()
+		text := scannedLog[start:end]
+		if !strings.HasPrefix(text, want[i].first) || !strings.HasSuffix(text, want[i].last) {
+			t.Errorf("panic %d was found at:\n%s", i, text)
+		}
+	}
+	if i != len(want) {
+		t.Errorf("%d panics were found, rather than %d", i, len(want))
+	}
+	if err := scanner.Err(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPanicScannerFalseStart(t *testing.T) {
+	texts := map[string]string{
+		// a line mentioning a panic, with a different prefix, immediately
+		// before a panic
+		"adjacent": "worker: recovered from panic: request cancelled\n" + createdBy,
+		// a line mentioning a panic, followed by other lines of its source
+		// and then by a panic
+		"distant": "worker: recovered from panic: request cancelled\n" +
+			strings.Repeat("worker: retrying request\n", maxHeaderDistance+1) + createdBy,
+	}
+
+	for name, text := range texts {
+		scanner := NewPanicScanner(strings.NewReader(text))
+		if !scanner.Scan() || scanner.Panic().Error() != "hello!" {
+			t.Errorf("%s: the panic following the false start was not found", name)
+			continue
+		}
+		if start, _ := scanner.Offsets(); start != int64(len(text)-len(createdBy)) {
+			t.Errorf("%s: the panic following the false start was found at %d", name, start)
+		}
+		if scanner.Scan() {
+			t.Errorf("%s: too many panics were found: %v", name, scanner.Panic())
+		}
+	}
+}
+
+func TestPanicScannerFatalDetails(t *testing.T) {
+	text, err := ioutil.ReadFile(filepath.Join("testdata", "fatal", "go1.27-stack-overflow.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := ParsePanic(string(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a line of another source mentioning the runtime, then the fatal error,
+	// each of whose lines is prefixed with a timestamp
+	var log strings.Builder
+	log.WriteString("worker: runtime: 3 goroutines\n")
+	start := log.Len()
+	for _, line := range strings.SplitAfter(strings.TrimSuffix(string(text), "\n"), "\n") {
+		log.WriteString("2024-01-02T03:04:05Z " + line)
+	}
+
+	scanner := NewPanicScanner(strings.NewReader(log.String()))
+	if !scanner.Scan() {
+		t.Fatalf("the fatal error was not found: %v", scanner.Err())
+	}
+	fatal, ok := As[*FatalError](scanner.Panic())
+	if !ok || !reflect.DeepEqual(fatal.Details, want.Underlying.(*FatalError).Details) {
+		t.Errorf("the details of the fatal error were not found: %+v", fatal)
+	}
+	if first, _ := scanner.Offsets(); first != int64(start) {
+		t.Errorf("the fatal error was found at %d, rather than %d", first, start)
+	}
+}
+
+func TestPanicScannerAdjacent(t *testing.T) {
+	text := strings.Repeat(createdBy, 3)
+
+	scanner := NewPanicScanner(strings.NewReader(text))
+	n := 0
+	for scanner.Scan() {
+		if scanner.Panic().Error() != "hello!" {
+			t.Errorf("panic %d was parsed as %q", n, scanner.Panic().Error())
+		}
+		n++
+	}
+	if n != 3 {
+		t.Errorf("%d panics were found, rather than 3", n)
+	}
+}
+
+func TestPanicScannerLarge(t *testing.T) {
+	var text strings.Builder
+	text.WriteString("panic: too many goroutines\n\ngoroutine 1 [running]:\nmain.main()\n\t/src/main.go:10 +0x25\n\n")
+	for i := 2; text.Len() <= maxScannedPanic; i++ {
+		fmt.Fprintf(&text, "goroutine %d [chan receive]:\nmain.worker()\n\t/src/main.go:5 +0x1d\ncreated by main.main in goroutine 1\n\t/src/main.go:9 +0x25\n\n", i)
+	}
+	text.WriteString(strings.Repeat("x", 2*maxScannedLine) + "\n")
+	text.WriteString(createdBy)
+
+	scanner := NewPanicScanner(strings.NewReader(text.String()))
+	if !scanner.Scan() || scanner.Panic().Error() != "too many goroutines" {
+		t.Fatalf("the large panic was not found: %v", scanner.Err())
+	}
+	if Err := scanner.Panic(); len(Err.StackFrames()) != 1 {
+		t.Errorf("the stack of the large panic was parsed as:\n%s", Err.StackFrames())
+	}
+	if !scanner.Scan() || scanner.Panic().Error() != "hello!" {
+		t.Fatalf("the panic following the overlong line was not found: %v", scanner.Err())
+	}
+	if start, _ := scanner.Offsets(); start != int64(text.Len()-len(createdBy)) {
+		t.Errorf("the panic following the overlong line was found at %d", start)
+	}
+	if scanner.Scan() {
+		t.Errorf("too many panics were found: %v", scanner.Panic())
+	}
+}
+
+func TestPanicScannerError(t *testing.T) {
+	readErr := fmt.Errorf("read failed")
+	scanner := NewPanicScanner(io.MultiReader(strings.NewReader(createdBy), iotest.ErrReader(readErr)))
+
+	if !scanner.Scan() || scanner.Panic().Error() != "hello!" {
+		t.Errorf("the panic read before the error was not found")
+	}
+	if scanner.Scan() {
+		t.Errorf("a panic was found after the error")
+	}
+	if scanner.Err() != readErr {
+		t.Errorf("the error was %v", scanner.Err())
+	}
+}
@@ -0,0 +1,189 @@
+package errors
+
+import (
+	"bytes"
+	"hash/fnv"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// A Stack is a callstack, as a list of frames starting with the innermost
+// function call.  The stacks returned by StackFrames and ParentStackFrames are
+// shared, and must not be modified; the methods of Stack never modify the
+// Stack they are called on, returning a new Stack instead.
+type Stack []StackFrame
+
+// NewStack returns the Stack of the given program counters, as returned by
+// runtime.Callers or by the Callers method of *Err.
+func NewStack(pcs []uintptr) Stack {
+	return newStackFrames(pcs)
+}
+
+// NewStackFromTrace returns the Stack of the given github.com/pkg/errors
+// stack trace, as returned by the StackTrace method of *Err or of errors made
+// by that package.
+func NewStackFromTrace(st errors.StackTrace) Stack {
+	pcs := make([]uintptr, len(st))
+	for i, frame := range st {
+		pcs[i] = uintptr(frame)
+	}
+	return NewStack(pcs)
+}
+
+// Filter returns the frames of the stack for which pred returns true.
+func (s Stack) Filter(pred func(StackFrame) bool) Stack {
+	filtered := make(Stack, 0, len(s))
+	for _, frame := range s {
+		if pred(frame) {
+			filtered = append(filtered, frame)
+		}
+	}
+	return filtered
+}
+
+// TrimRuntime returns the stack without the frames of functions in the
+// runtime package, such as runtime.main and runtime.goexit at the bottom of
+// every stack, and runtime.gopanic within the stack of a panic.
+func (s Stack) TrimRuntime() Stack {
+	return s.Filter(func(frame StackFrame) bool {
+		return frame.Package != "runtime"
+	})
+}
+
+// TrimBelow returns the stack up to and including the first frame of the
+// function called name, dropping the frames of the functions that led to
+// its call.  The name is matched as described by Contains.  The whole stack
+// is returned if no frame matches.
+func (s Stack) TrimBelow(name string) Stack {
+	for i := range s {
+		if s[i].isFunc(name) {
+			return s[: i+1 : i+1]
+		}
+	}
+	return s
+}
+
+// Contains reports whether any frame of the stack is in the package, or of
+// the function, called name.  Packages are matched by their full path, such
+// as "net/http", and functions by their name within their package, such as
+// "(*Server).Serve", or by their fully qualified name, such as
+// "net/http.(*Server).Serve".
+func (s Stack) Contains(name string) bool {
+	for i := range s {
+		if s[i].Package == name || s[i].isFunc(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Top returns the n innermost frames of the stack, or the whole stack if it
+// has fewer.
+func (s Stack) Top(n int) Stack {
+	if n < 0 {
+		n = 0
+	}
+	if n > len(s) {
+		n = len(s)
+	}
+	return s[:n:n]
+}
+
+// Hash returns a hash of the functions, files and line numbers of the
+// frames of the stack, such that stacks passing through the same calls hash
+// the same, even when parsed from the output of a panic rather than
+// captured, or captured by different runs of a program.
+func (s Stack) Hash() uint64 {
+	h := fnv.New64a()
+	var scratch [20]byte
+	for i := range s {
+		h.Write([]byte(s[i].Package))
+		h.Write([]byte{0})
+		h.Write([]byte(s[i].Name))
+		h.Write([]byte{0})
+		h.Write([]byte(s[i].File))
+		h.Write([]byte{0})
+		h.Write(strconv.AppendInt(scratch[:0], int64(s[i].LineNumber), 10))
+		h.Write([]byte{0})
+	}
+	return h.Sum64()
+}
+
+// maxCycle is the length of the longest cycle of frames found by Collapsed.
+const maxCycle = 32
+
+// Collapsed returns the stack with each run of frames that repeat, such as
+// those of a recursive function, or cycle of frames that repeat, such as
+// those of mutually recursive functions, collapsed into a single copy of the
+// frames followed by a StackFrame marking how many times they were repeated.
+// Runs are only collapsed where doing so shortens the stack.
+func (s Stack) Collapsed() Stack {
+	collapsed := make(Stack, 0, len(s))
+	for i := 0; i < len(s); {
+		// the cycle starting at i that collapses the most frames
+		cycle, repeated := 1, 1
+		for length := 1; length <= maxCycle && i+2*length <= len(s); length++ {
+			n := s.repeats(i, length)
+			if (n-1)*length > (repeated-1)*cycle {
+				cycle, repeated = length, n
+			}
+		}
+
+		// a marker is a frame itself, so a single frame repeated twice is
+		// not collapsed
+		if (repeated-1)*cycle <= 1 {
+			collapsed = append(collapsed, s[i])
+			i++
+			continue
+		}
+		collapsed = append(collapsed, s[i:i+cycle]...)
+		collapsed = append(collapsed, StackFrame{Repeated: repeated, Cycle: cycle})
+		i += repeated * cycle
+	}
+	return collapsed
+}
+
+// repeats returns the number of times in a row that the length frames
+// starting at i appear, from i onwards.
+func (s Stack) repeats(i, length int) int {
+	n := 1
+	for next := i + length; next+length <= len(s); next += length {
+		for j := 0; j < length; j++ {
+			if s[i+j] != s[next+j] {
+				return n
+			}
+		}
+		n++
+	}
+	return n
+}
+
+// String returns the stack formatted the same way that go does in
+// runtime/debug.Stack(), as the Stack method of *Err formats its Collapsed
+// stack.
+func (s Stack) String() string {
+	buf := bytes.Buffer{}
+	s.writeTo(&buf)
+	return buf.String()
+}
+
+// writeTo writes the stack to buf, formatted as described by String.
+func (s Stack) writeTo(buf *bytes.Buffer) {
+	for i := range s {
+		s[i].writeTo(buf)
+	}
+}
+
+// isFunc reports whether the function of the stackframe is called name,
+// either within its package or fully qualified.
+func (frame *StackFrame) isFunc(name string) bool {
+	if name == frame.Name {
+		return true
+	}
+	// compared in parts, as this is called for every frame of a stack
+	n := len(frame.Package)
+	return n > 0 && len(name) == n+1+len(frame.Name) && name[n] == '.' &&
+		strings.HasPrefix(name, frame.Package) && strings.HasSuffix(name, frame.Name)
+}
@@ -0,0 +1,22 @@
+package errors
+
+import (
+	"fmt"
+)
+
+func ExampleStack_TrimRuntime() {
+	err := New("something went wrong")
+
+	// only the frames of the program itself
+	stack := err.StackFrames().TrimRuntime()
+	fmt.Println(stack.Contains("runtime.goexit"))
+	// Output: false
+}
+
+func ExampleStack_Contains() {
+	err := New("something went wrong")
+
+	stack := err.StackFrames()
+	fmt.Println(stack.Contains("github.com/smquartz/errors"), stack.Contains("ExampleStack_Contains"))
+	// Output: true true
+}
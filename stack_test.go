@@ -0,0 +1,161 @@
+package errors
+
+import (
+	"strings"
+	"testing"
+)
+
+// a stack such as one parsed from the output of a panic
+var testStack = Stack{
+	{File: "/src/a/a.go", LineNumber: 12, Name: "(*T).M", Package: "example.com/a", Receiver: "*T", Function: "M"},
+	{File: "/go/src/runtime/panic.go", LineNumber: 770, Name: "gopanic", Package: "runtime", Function: "gopanic"},
+	{File: "/src/a/a.go", LineNumber: 20, Name: "F", Package: "example.com/a", Function: "F"},
+	{File: "/src/main.go", LineNumber: 9, Name: "main", Package: "main", Function: "main"},
+	{File: "/go/src/runtime/proc.go", LineNumber: 250, Name: "main", Package: "runtime", Function: "main"},
+}
+
+func names(s Stack) []string {
+	names := make([]string, len(s))
+	for i, frame := range s {
+		names[i] = frame.Package + "." + frame.Name
+	}
+	return names
+}
+
+func TestStackTrim(t *testing.T) {
+	cases := map[string]struct {
+		stack Stack
+		want  []string
+	}{
+		"TrimRuntime":           {testStack.TrimRuntime(), []string{"example.com/a.(*T).M", "example.com/a.F", "main.main"}},
+		"TrimBelow":             {testStack.TrimBelow("F"), []string{"example.com/a.(*T).M", "runtime.gopanic", "example.com/a.F"}},
+		"TrimBelow qualified":   {testStack.TrimBelow("runtime.gopanic"), []string{"example.com/a.(*T).M", "runtime.gopanic"}},
+		"TrimBelow not found":   {testStack.TrimBelow("G"), names(testStack)},
+		"Top":                   {testStack.Top(2), []string{"example.com/a.(*T).M", "runtime.gopanic"}},
+		"Top more than present": {testStack.Top(10), names(testStack)},
+		"Top negative":          {testStack.Top(-1), []string{}},
+		"Filter": {testStack.Filter(func(frame StackFrame) bool {
+			return frame.Receiver != ""
+		}), []string{"example.com/a.(*T).M"}},
+	}
+
+	for name, c := range cases {
+		got := names(c.stack)
+		if len(got) != len(c.want) {
+			t.Errorf("%s returned %v, rather than %v", name, got, c.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != c.want[i] {
+				t.Errorf("%s returned %v, rather than %v", name, got, c.want)
+				break
+			}
+		}
+	}
+}
+
+func TestStackTrimDoesNotModify(t *testing.T) {
+	stack := append(Stack{}, testStack...)
+	top := stack.Top(1)
+	top = append(top, StackFrame{Name: "appended"})
+	if stack[1].Name != "gopanic" {
+		t.Errorf("appending to the top of a stack modified the stack")
+	}
+}
+
+func TestStackContains(t *testing.T) {
+	cases := map[string]bool{
+		"example.com/a":          true,
+		"example.com/a.(*T).M":   true,
+		"(*T).M":                 true,
+		"gopanic":                true,
+		"runtime.gopanic":        true,
+		"example.com/a.gopanic":  false,
+		"example.com":            false,
+		"example.com/a.(*T).Mx":  false,
+		"net/http.(*Server).Run": false,
+	}
+
+	for name, want := range cases {
+		if got := testStack.Contains(name); got != want {
+			t.Errorf("Contains(%q) = %t", name, got)
+		}
+	}
+}
+
+func TestStackHash(t *testing.T) {
+	if testStack.Hash() != append(Stack{}, testStack...).Hash() {
+		t.Errorf("identical stacks hashed differently")
+	}
+	if testStack.Hash() == testStack.Top(4).Hash() {
+		t.Errorf("different stacks hashed the same")
+	}
+
+	// stacks of the same calls hash the same, wherever they were resolved
+	var errs [2]*Err
+	for i := range errs {
+		errs[i] = New(testMsgFoo)
+	}
+	a, b := errs[0].StackFrames(), NewStack(errs[1].Callers())
+	if a.Hash() != b.Hash() {
+		t.Errorf("stacks of the same calls hashed differently")
+	}
+}
+
+func TestStackString(t *testing.T) {
+	err := New(testMsgFoo)
+	if err.StackFrames().String() != string(err.Stack()) {
+		t.Errorf("the stack was formatted differently to .Stack()")
+	}
+	if NewStackFromTrace(err.StackTrace()).String() != string(err.Stack()) {
+		t.Errorf("the stack of a github.com/pkg/errors stack trace was formatted differently to .Stack()")
+	}
+}
+
+func TestStackCollapsed(t *testing.T) {
+	a := StackFrame{File: "/src/a.go", LineNumber: 1, Name: "a", Package: "main", Function: "a"}
+	b := StackFrame{File: "/src/b.go", LineNumber: 2, Name: "b", Package: "main", Function: "b"}
+	c := StackFrame{File: "/src/c.go", LineNumber: 3, Name: "c", Package: "main", Function: "c"}
+
+	cases := map[string]struct {
+		stack, want Stack
+	}{
+		"no repeats":     {Stack{a, b, c}, Stack{a, b, c}},
+		"repeated twice": {Stack{a, a, b}, Stack{a, a, b}},
+		"recursion":      {Stack{c, a, a, a, a, b}, Stack{c, a, {Repeated: 4, Cycle: 1}, b}},
+		"cycle":          {Stack{a, b, a, b, a, b, c}, Stack{a, b, {Repeated: 3, Cycle: 2}, c}},
+		"cycle twice":    {Stack{a, b, a, b, c}, Stack{a, b, {Repeated: 2, Cycle: 2}, c}},
+		"longest run": {Stack{a, b, b, b, a, b, b, b, c},
+			Stack{a, b, b, b, {Repeated: 2, Cycle: 4}, c}},
+	}
+
+	for name, c := range cases {
+		got := c.stack.Collapsed()
+		if len(got) != len(c.want) {
+			t.Errorf("%s collapsed to %+v", name, got)
+			continue
+		}
+		for i := range got {
+			if got[i] != c.want[i] {
+				t.Errorf("%s collapsed to %+v", name, got)
+				break
+			}
+		}
+	}
+}
+
+func TestStackCollapsedString(t *testing.T) {
+	err := recurse(20)
+	stack := err.ErrorStack()
+	if !strings.Contains(stack, "\n... previous frame repeated 20 times ...\n") {
+		t.Errorf("a recursive stack was not collapsed:\n%s", stack)
+	}
+	if strings.Count(stack, "\trecurse: ") != 2 {
+		t.Errorf("the frames of a recursive stack were repeated:\n%s", stack)
+	}
+
+	// collapsed frames are marshalled as they are rendered
+	if _, decoded := roundTrip(t, err); decoded.ErrorStack() != stack {
+		t.Errorf("a recursive stack was marshalled differently to how it was rendered")
+	}
+}
@@ -2,10 +2,10 @@ package errors
 
 import (
 	"bytes"
-	"fmt"
 	"io/ioutil"
 	"runtime"
-	"strings"
+	"strconv"
+	"sync"
 )
 
 // A StackFrame contains all necessary information about to generate a line
@@ -19,84 +19,241 @@ type StackFrame struct {
 	Name string
 	// The Package that contains this function
 	Package string
-	// The underlying ProgramCounter
+	// The Receiver type of the method that is this function, such as "*T",
+	// if it is a method
+	Receiver string
+	// The Function, or method, without its receiver, type arguments and
+	// closures, such as "M" for "(*T).M.func1"
+	Function string
+	// The path of nested closures within Function, such as "func1" or
+	// "func2.1", if this function is a closure
+	Closure string
+	// The TypeParams of this function, or of its receiver type, as reported
+	// by the runtime, such as "...", if it is generic
+	TypeParams string
+	// The underlying ProgramCounter, as returned by runtime.Callers.  Frames
+	// expanded from a single program counter share it.
 	ProgramCounter uintptr
+	// Whether this function was inlined into its caller by the compiler, and
+	// so has no physical frame of its own
+	Inlined bool
+	// The number of frames Elided in place of this one, from the middle of a
+	// stack too deep to be captured whole, or -1 where go did not count the
+	// frames it elided from the bottom of a stack, as before go 1.21; such a
+	// frame describes no function
+	Elided int
+	// The number of times in a row that the Cycle frames before this one were
+	// Repeated, in a Collapsed stack; such a frame describes no function
+	Repeated int
+	// The length of the Cycle of frames before this one that was Repeated
+	Cycle int
 }
 
 // NewStackFrame popoulates a stack frame object from the program counter.
+// Where the compiler inlined several functions at pc, the frame returned is
+// that of the innermost of them; use runtime.CallersFrames, or the
+// StackFrames method of *Err, to expand every function inlined at pc.
 func NewStackFrame(pc uintptr) (frame StackFrame) {
 
 	frame = StackFrame{ProgramCounter: pc}
 	if frame.Func() == nil {
 		return
 	}
-	frame.Package, frame.Name = packageAndName(frame.Func())
 
-	// pc -1 because the program counters we use are usually return addresses,
-	// and we want to show the line that corresponds to the function call
-	frame.File, frame.LineNumber = frame.Func().FileLine(pc - 1)
-	return
+	frames := runtime.CallersFrames([]uintptr{pc})
+	f, _ := frames.Next()
+	return newStackFrame(f)
 
 }
 
-// Func returns the function that contained this frame.
+// newStackFrames returns the frames of stack, a stack as returned by
+// runtime.Callers.  Program counters are expanded with runtime.CallersFrames,
+// so that functions inlined by the compiler are reported correctly, each as
+// a frame of its own.
+func newStackFrames(stack []uintptr) []StackFrame {
+	if len(stack) == 0 {
+		return []StackFrame{}
+	}
+
+	result := make([]StackFrame, 0, len(stack))
+	frames := runtime.CallersFrames(stack)
+	for {
+		f, more := frames.Next()
+		result = append(result, newStackFrame(f))
+		if !more {
+			return result
+		}
+	}
+}
+
+// newStackFrame returns the StackFrame describing f.
+func newStackFrame(f runtime.Frame) StackFrame {
+	frame := StackFrame{
+		File:       f.File,
+		LineNumber: f.Line,
+		// runtime.CallersFrames subtracts 1 from the program counters
+		// returned by runtime.Callers, so that they refer to the call rather
+		// than the return address, and this is undone so that ProgramCounter
+		// can be compared with the program counters returned by Callers
+		ProgramCounter: f.PC + 1,
+		// only the outermost function in a physical frame has a Func
+		Inlined: f.Func == nil && f.Function != "",
+	}
+	if f.Function != "" {
+		frame.setFuncName(f.Function)
+	}
+	return frame
+}
+
+// setFuncName sets the fields of the stackframe that describe its function
+// from symbol, the fully qualified name of that function.
+func (frame *StackFrame) setFuncName(symbol string) {
+	f := parseFuncName(symbol)
+	frame.Package, frame.Name = f.pkg, f.name
+	frame.Receiver, frame.Function = f.receiver, f.function
+	frame.Closure, frame.TypeParams = f.closure, f.typeParams
+}
+
+// Func returns the function that contained this frame.  Where several
+// frames share a ProgramCounter, the function returned is the innermost of
+// them.
 func (frame *StackFrame) Func() *runtime.Func {
 	if frame.ProgramCounter == 0 {
 		return nil
 	}
-	return runtime.FuncForPC(frame.ProgramCounter)
+	// ProgramCounter is a return address, which may already lie outside the
+	// function inlined at the call, so the call itself is looked up instead
+	return runtime.FuncForPC(frame.ProgramCounter - 1)
 }
 
 // String returns the stackframe formatted in the same way as go does
-// in runtime/debug.Stack()
+// in runtime/debug.Stack(), or as "... N frames elided ...", "... additional
+// frames elided ..." or "... previous N frames repeated M times ..." if it
+// marks elided or repeated frames
 func (frame *StackFrame) String() string {
-	str := fmt.Sprintf("%s:%d (0x%x)\n", frame.File, frame.LineNumber, frame.ProgramCounter)
+	buf := bytes.Buffer{}
+	frame.writeTo(&buf)
+	return buf.String()
+}
+
+// writeTo writes the stackframe to buf, formatted as described by String,
+// without the intermediate allocations that formatting each part as a
+// string would require.
+func (frame *StackFrame) writeTo(buf *bytes.Buffer) {
+	var scratch [20]byte
+	if frame.Elided < 0 {
+		buf.WriteString("... additional frames elided ...\n")
+		return
+	}
+	if frame.Elided > 0 {
+		buf.WriteString("... ")
+		buf.Write(strconv.AppendInt(scratch[:0], int64(frame.Elided), 10))
+		buf.WriteString(" frames elided ...\n")
+		return
+	}
+	if frame.Repeated > 0 {
+		if frame.Cycle == 1 {
+			buf.WriteString("... previous frame")
+		} else {
+			buf.WriteString("... previous ")
+			buf.Write(strconv.AppendInt(scratch[:0], int64(frame.Cycle), 10))
+			buf.WriteString(" frames")
+		}
+		buf.WriteString(" repeated ")
+		buf.Write(strconv.AppendInt(scratch[:0], int64(frame.Repeated), 10))
+		buf.WriteString(" times ...\n")
+		return
+	}
 
-	source, err := frame.SourceLine()
+	buf.WriteString(frame.File)
+	buf.WriteByte(':')
+	buf.Write(strconv.AppendInt(scratch[:0], int64(frame.LineNumber), 10))
+	buf.WriteString(" (0x")
+	buf.Write(strconv.AppendUint(scratch[:0], uint64(frame.ProgramCounter), 16))
+	buf.WriteString(")\n")
+
+	source, err := frame.sourceLine()
 	if err != nil {
-		return str
+		return
 	}
 
-	return str + fmt.Sprintf("\t%s: %s\n", frame.Name, source)
+	buf.WriteByte('\t')
+	buf.WriteString(frame.Name)
+	buf.WriteString(": ")
+	buf.Write(source)
+	buf.WriteByte('\n')
 }
 
 // SourceLine gets the line of code (from File and Line) of the original source if possible.
 func (frame *StackFrame) SourceLine() (string, error) {
-	data, err := ioutil.ReadFile(frame.File)
+	source, err := frame.sourceLine()
 
 	if err != nil {
 		return "", New(err)
 	}
 
-	lines := bytes.Split(data, []byte{'\n'})
+	return string(source), nil
+}
+
+// sourceLine returns the line of code as described by SourceLine, without
+// copying it from the cache of source files.
+func (frame *StackFrame) sourceLine() ([]byte, error) {
+	lines, err := sourceLines(frame.File)
+
+	if err != nil {
+		return nil, err
+	}
+
 	if frame.LineNumber <= 0 || frame.LineNumber >= len(lines) {
-		return "???", nil
+		return []byte("???"), nil
 	}
 	// -1 because line-numbers are 1 based, but our array is 0 based
-	return string(bytes.Trim(lines[frame.LineNumber-1], " \t")), nil
+	return bytes.Trim(lines[frame.LineNumber-1], " \t"), nil
+}
+
+// maxCachedSourceFiles bounds the number of source files whose lines are
+// cached by sourceLines.
+const maxCachedSourceFiles = 64
+
+// sourceFile is a source file cached by sourceLines: either its lines, or
+// the error encountered reading it.
+type sourceFile struct {
+	lines [][]byte
+	err   error
 }
 
-func packageAndName(fn *runtime.Func) (string, string) {
-	name := fn.Name()
-	pkg := ""
-
-	// The name includes the path name to the package, which is unnecessary
-	// since the file name is already included.  Plus, it has center dots.
-	// That is, we see
-	//  runtime/debug.*T·ptrmethod
-	// and want
-	//  *T.ptrmethod
-	// Since the package path might contains dots (e.g. code.google.com/...),
-	// we first remove the path prefix if there is one.
-	if lastslash := strings.LastIndex(name, "/"); lastslash >= 0 {
-		pkg += name[:lastslash] + "/"
-		name = name[lastslash+1:]
-	}
-	if period := strings.Index(name, "."); period >= 0 {
-		pkg += name[:period]
-		name = name[period+1:]
-	}
-
-	name = strings.Replace(name, "·", ".", -1)
-	return pkg, name
+// sourceCache caches the source files read by sourceLines, as rendering a
+// stack would otherwise read a file once for every frame in it.  Files that
+// could not be read are cached too, as the sources of a program are often
+// not deployed alongside it.
+var sourceCache = struct {
+	sync.Mutex
+	files map[string]sourceFile
+}{files: make(map[string]sourceFile)}
+
+// sourceLines returns the lines of the given source file, reading it only if
+// it is not already cached.
+func sourceLines(file string) ([][]byte, error) {
+	sourceCache.Lock()
+	cached, ok := sourceCache.files[file]
+	sourceCache.Unlock()
+	if ok {
+		return cached.lines, cached.err
+	}
+
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		cached.err = err
+	} else {
+		cached.lines = bytes.Split(data, []byte{'\n'})
+	}
+
+	sourceCache.Lock()
+	if len(sourceCache.files) >= maxCachedSourceFiles {
+		// start afresh, rather than tracking which files are least used
+		sourceCache.files = make(map[string]sourceFile)
+	}
+	sourceCache.files[file] = cached
+	sourceCache.Unlock()
+	return cached.lines, cached.err
 }
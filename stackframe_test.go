@@ -1,7 +1,11 @@
 package errors
 
 import (
+	"bytes"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
 	"testing"
 )
 
@@ -26,3 +30,160 @@ func TestStackFromeSourceLineWithNonsenseLineNumber(t *testing.T) {
 		t.Errorf("frame.SourceLine() did not recognise a nonsense line number")
 	}
 }
+
+// inlinedNew is small enough to be inlined into its caller by the compiler.
+func inlinedNew() *Err {
+	return New(testMsgFoo)
+}
+
+// callsInlined is not inlined, so that it has a physical frame of its own
+// that inlinedNew is inlined into.
+//
+//go:noinline
+func callsInlined() *Err {
+	return inlinedNew()
+}
+
+func TestStackFramesInlined(t *testing.T) {
+	err := callsInlined()
+	frames := err.StackFrames()
+	if len(frames) < 2 {
+		t.Fatalf("only %d frames were reported", len(frames))
+	}
+
+	inlined, caller := frames[0], frames[1]
+	if inlined.Name != "inlinedNew" || !inlined.Inlined {
+		t.Errorf("the inlined function was reported as %s (inlined: %t)", inlined.Name, inlined.Inlined)
+	}
+	if caller.Name != "callsInlined" || caller.Inlined {
+		t.Errorf("the function it was inlined into was reported as %s (inlined: %t)", caller.Name, caller.Inlined)
+	}
+	if !strings.HasSuffix(inlined.File, "stackframe_test.go") || inlined.LineNumber == caller.LineNumber {
+		t.Errorf("the inlined function was reported at %s:%d", inlined.File, inlined.LineNumber)
+	}
+}
+
+func TestStackFramesProgramCounter(t *testing.T) {
+	err := callsInlined()
+	frames, callers := err.StackFrames(), err.Callers()
+	if len(frames) != len(callers) {
+		t.Fatalf("%d frames were reported for %d program counters", len(frames), len(callers))
+	}
+
+	for i, frame := range frames {
+		if frame.ProgramCounter != callers[i] {
+			t.Errorf("frame %d has program counter 0x%x, rather than 0x%x", i, frame.ProgramCounter, callers[i])
+		}
+		if fn := frame.Func(); fn == nil || !strings.HasSuffix(fn.Name(), "."+frame.Name) {
+			t.Errorf("frame %d of %s does not describe its own function", i, frame.Name)
+		}
+	}
+
+	single := NewStackFrame(callers[0])
+	if single != frames[0] {
+		t.Errorf("NewStackFrame reported %+v, rather than %+v", single, frames[0])
+	}
+}
+
+// genericHolder and newGenericErr are generic, and are not inlined so that
+// each has a frame of its own.
+
+type genericHolder[T any] struct{ value T }
+
+//go:noinline
+func (h *genericHolder[T]) newErr() *Err {
+	return New(testMsgFoo)
+}
+
+//go:noinline
+func newGenericErr[T any](value T) *Err {
+	return func() *Err {
+		return (&genericHolder[T]{value}).newErr()
+	}()
+}
+
+func TestStackFramesFuncName(t *testing.T) {
+	frames := newGenericErr(1).StackFrames()
+	if len(frames) < 3 {
+		t.Fatalf("only %d frames were reported", len(frames))
+	}
+
+	method, closure, generic := frames[0], frames[1], frames[2]
+	if method.Receiver != "*genericHolder" || method.Function != "newErr" || method.TypeParams == "" {
+		t.Errorf("the method was reported as %+v", method)
+	}
+	if closure.Function != "newGenericErr" || closure.Closure != "func1" {
+		t.Errorf("the closure was reported as %+v", closure)
+	}
+	if generic.Function != "newGenericErr" || generic.Closure != "" || generic.TypeParams == "" {
+		t.Errorf("the generic function was reported as %+v", generic)
+	}
+	for _, frame := range frames[:3] {
+		if frame.Package != "github.com/smquartz/errors" {
+			t.Errorf("%s was reported in package %s", frame.Name, frame.Package)
+		}
+	}
+}
+
+func TestStackFrameSourceLineMissingFileCached(t *testing.T) {
+	frame := StackFrame{File: "/nonexistent/source.go", LineNumber: 1}
+	if _, err := frame.SourceLine(); err == nil {
+		t.Fatalf("frame.SourceLine() was somehow able to read a nonexistent file")
+	}
+
+	sourceCache.Lock()
+	cached, ok := sourceCache.files[frame.File]
+	sourceCache.Unlock()
+	if !ok || cached.err == nil {
+		t.Errorf("the failure to read a nonexistent file was not cached")
+	}
+	if _, err := frame.SourceLine(); err == nil {
+		t.Errorf("frame.SourceLine() read a nonexistent file from the cache")
+	}
+}
+
+// sourceLineUncached reads a line of code in the way SourceLine did before
+// source files were cached, for comparison by the benchmarks below.
+func sourceLineUncached(frame *StackFrame) ([]byte, error) {
+	data, err := ioutil.ReadFile(frame.File)
+	if err != nil {
+		return nil, err
+	}
+	lines := bytes.Split(data, []byte{'\n'})
+	if frame.LineNumber <= 0 || frame.LineNumber >= len(lines) {
+		return []byte("???"), nil
+	}
+	return bytes.Trim(lines[frame.LineNumber-1], " \t"), nil
+}
+
+func BenchmarkSourceLine(b *testing.B) {
+	frame := New(io.EOF).StackFrames()[0]
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_, _ = frame.sourceLine()
+	}
+}
+
+func BenchmarkSourceLineUncached(b *testing.B) {
+	frame := New(io.EOF).StackFrames()[0]
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_, _ = sourceLineUncached(&frame)
+	}
+}
+
+func BenchmarkSourceLineMissingFile(b *testing.B) {
+	frame := StackFrame{File: "/nonexistent/source.go", LineNumber: 1}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_, _ = frame.sourceLine()
+	}
+}
+
+func BenchmarkSourceLineMissingFileUncached(b *testing.B) {
+	frame := StackFrame{File: "/nonexistent/source.go", LineNumber: 1}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_, _ = sourceLineUncached(&frame)
+	}
+}